@@ -23,14 +23,29 @@ func (m *multiValueFlag) Set(value string) error {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "diff":
+			runDiff(os.Args[2:])
+			return
+		case "replay":
+			runReplay(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-s] -t <testcases1.yml|testcasesdir1> [-t <testcases2.yml|testcasesdir2> ...] <istioconfig1.yml|istioconfigdir1> [<istioconfig2.yml|istioconfigdir2> ...]\n\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-s] [-check-destinations] -t <testcases1.yml|testcasesdir1> [-t <testcases2.yml|testcasesdir2> ...] <istioconfig1.yml|istioconfigdir1> [<istioconfig2.yml|istioconfigdir2> ...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s diff [-s] [-t <testcases1.yml|testcasesdir1> ...] <base istioconfigdir> <head istioconfigdir>\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s replay [-s] [-H <header> ...] -l <accesslog1|accesslogdir1> [-l <accesslog2|accesslogdir2> ...] <istioconfig1.yml|istioconfigdir1> [...]\n\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 	var testCaseParams multiValueFlag
 	flag.Var(&testCaseParams, "t", "Testcase files/folders")
 	summaryOnly := flag.Bool("s", false, "show only summary of tests (in case of failures full details are shown)")
 	strict := flag.Bool("strict", false, "fail on unknown fields")
+	checkDestinations := flag.Bool("check-destinations", false, "fail when a destination does not resolve to a kubernetes service or service entry of the istio config")
+	pathNormalization := flag.String("path-normalization", "BASE", "path normalization applied to requests, one of NONE, BASE, MERGE_SLASHES or DECODE_AND_MERGE_SLASHES (test cases may override it)")
 
 	flag.Parse()
 	istioConfigFiles := getFiles(flag.Args())
@@ -47,7 +62,100 @@ func main() {
 		os.Exit(1)
 	}
 
-	summary, details, err := unit.Run(testCaseFiles, istioConfigFiles, *strict)
+	normalization, err := unit.ParsePathNormalization(*pathNormalization)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	summary, details, err := unit.Run(testCaseFiles, istioConfigFiles, *strict, unit.WithPathNormalization(normalization), unit.WithDestinationCheck(*checkDestinations))
+	if err != nil {
+		fmt.Println(strings.Join(details, "\n"))
+		log.Fatal(err.Error())
+	}
+	if !*summaryOnly {
+		fmt.Println(strings.Join(details, "\n"))
+		fmt.Println("")
+	}
+	fmt.Println(strings.Join(summary, "\n"))
+}
+
+// runDiff reports the requests routed differently by two revisions of the istio config.
+func runDiff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s diff [-s] [-t <testcases1.yml|testcasesdir1> ...] <base istioconfigdir> <head istioconfigdir>\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	var testCaseParams multiValueFlag
+	flags.Var(&testCaseParams, "t", "Testcase files/folders whose requests are added to the generated ones")
+	summaryOnly := flags.Bool("s", false, "show only summary of the diff")
+	strict := flags.Bool("strict", false, "fail on unknown fields")
+	pathNormalization := flags.String("path-normalization", "BASE", "path normalization applied to requests, one of NONE, BASE, MERGE_SLASHES or DECODE_AND_MERGE_SLASHES (test cases may override it)")
+
+	_ = flags.Parse(args)
+	if flags.NArg() != 2 {
+		fmt.Fprintf(os.Stderr, "Expected the base and head istio config files/folders, got %d arguments\n", flags.NArg())
+		flags.Usage()
+		os.Exit(1)
+	}
+	baseConfigFiles := getFiles(flags.Args()[:1])
+	headConfigFiles := getFiles(flags.Args()[1:])
+	normalization, err := unit.ParsePathNormalization(*pathNormalization)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	summary, details, err := unit.Diff(getFiles(testCaseParams), baseConfigFiles, headConfigFiles, *strict, unit.WithPathNormalization(normalization))
+	if err != nil {
+		fmt.Println(strings.Join(details, "\n"))
+		log.Fatal(err.Error())
+	}
+	if !*summaryOnly {
+		fmt.Println(strings.Join(details, "\n"))
+		fmt.Println("")
+	}
+	fmt.Println(strings.Join(summary, "\n"))
+}
+
+// runReplay routes the requests of Envoy access logs through the istio config.
+func runReplay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s replay [-s] [-H <header> ...] -l <accesslog1|accesslogdir1> [-l <accesslog2|accesslogdir2> ...] <istioconfig1.yml|istioconfigdir1> [<istioconfig2.yml|istioconfigdir2> ...]\n\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	var logParams, headers multiValueFlag
+	flags.Var(&logParams, "l", "Envoy access log files/folders, in the default text format or in JSON")
+	flags.Var(&headers, "H", "Request header to read from the access logs (user-agent, x-forwarded-for, x-request-id or any JSON field)")
+	summaryOnly := flags.Bool("s", false, "show only summary of the replay")
+	pathNormalization := flags.String("path-normalization", "BASE", "path normalization applied to requests, one of NONE, BASE, MERGE_SLASHES or DECODE_AND_MERGE_SLASHES")
+
+	_ = flags.Parse(args)
+	logFiles := getAllFiles(logParams)
+	istioConfigFiles := getFiles(flags.Args())
+	if len(logFiles) < 1 {
+		fmt.Fprintf(os.Stderr, "Missing access log file/folder, please provide at least one access log file or folder\n")
+		flags.Usage()
+		os.Exit(1)
+	}
+	if len(istioConfigFiles) < 1 {
+		fmt.Fprintf(os.Stderr, "Missing istio config file/folder, please provide at least one istio config file or folder\n")
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	normalization, err := unit.ParsePathNormalization(*pathNormalization)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		flags.Usage()
+		os.Exit(1)
+	}
+
+	summary, details, err := unit.Replay(logFiles, istioConfigFiles, headers, unit.WithPathNormalization(normalization))
 	if err != nil {
 		fmt.Println(strings.Join(details, "\n"))
 		log.Fatal(err.Error())
@@ -60,13 +168,21 @@ func main() {
 }
 
 func getFiles(names []string) []string {
+	return walkFiles(names, isYaml)
+}
+
+func getAllFiles(names []string) []string {
+	return walkFiles(names, func(os.FileInfo) bool { return true })
+}
+
+func walkFiles(names []string, filter func(os.FileInfo) bool) []string {
 	var files []string
 	for _, name := range names {
 		err := filepath.Walk(name, func(path string, info os.FileInfo, err error) error {
 			if err != nil {
 				log.Fatal(err.Error())
 			}
-			if !info.IsDir() && isYaml(info) {
+			if !info.IsDir() && filter(info) {
 				files = append(files, path)
 			}
 			return nil
@@ -0,0 +1,140 @@
+// Package authz evaluates istio AuthorizationPolicies against crafted requests. It intends to replicate the
+// decisions of the istio authorization filter for the workload receiving the requests.
+package authz
+
+import (
+	"fmt"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	security "istio.io/api/security/v1"
+	securityv1 "istio.io/client-go/pkg/apis/security/v1"
+)
+
+// RootNamespace is the istio root namespace, whose policies and sidecar resource apply to the workloads of every
+// namespace.
+const RootNamespace = "istio-system"
+
+// Request is a request received by a workload.
+type Request struct {
+	parser.Input
+	// Workload is the workload receiving the request.
+	Workload parser.Workload
+	// Source is the workload sending the request, nil for requests from outside the mesh.
+	Source *parser.Source
+}
+
+// Decision is the outcome of the authorization of a request.
+type Decision struct {
+	Allowed bool
+	// Policy is the namespace/name of the policy which decided, empty when none did: requests are allowed when
+	// no ALLOW policy applies to the workload, denied when none of the applying ones matches.
+	Policy string
+	// Rule is the index of the rule of the policy which decided.
+	Rule int
+	// Custom is the namespace/name of the CUSTOM policy delegating the request to an external authorizer, which
+	// is assumed to allow it.
+	Custom string
+}
+
+func (d Decision) String() string {
+	out := "denied"
+	if d.Allowed {
+		out = "allowed"
+	}
+	if d.Policy != "" {
+		out += fmt.Sprintf(" by %s rule %d", d.Policy, d.Rule)
+	}
+	if d.Custom != "" {
+		out += fmt.Sprintf(" after %s", d.Custom)
+	}
+	return out
+}
+
+// Evaluate returns the decision of the policies applying to the workload receiving the request. As in istio,
+// CUSTOM policies are evaluated first, then DENY ones and then ALLOW ones. AUDIT policies do not decide.
+func Evaluate(policies []*securityv1.AuthorizationPolicy, request Request) (Decision, error) {
+	var out Decision
+	var allowPolicies int
+	for _, action := range []security.AuthorizationPolicy_Action{security.AuthorizationPolicy_CUSTOM, security.AuthorizationPolicy_DENY, security.AuthorizationPolicy_ALLOW} {
+		for _, policy := range policies {
+			if policy.Spec.Action != action || !appliesTo(policy, request.Workload) {
+				continue
+			}
+			if action == security.AuthorizationPolicy_ALLOW {
+				allowPolicies++
+			}
+			rule, err := matchPolicy(policy, request)
+			if err != nil {
+				return out, fmt.Errorf("evaluating authorizationpolicy %s/%s failed: %w", policy.Namespace, policy.Name, err)
+			}
+			if rule < 0 {
+				continue
+			}
+			name := policy.Namespace + "/" + policy.Name
+			switch action {
+			case security.AuthorizationPolicy_CUSTOM:
+				if out.Custom == "" {
+					out.Custom = name
+				}
+			case security.AuthorizationPolicy_DENY:
+				out.Policy, out.Rule = name, rule
+				return out, nil
+			case security.AuthorizationPolicy_ALLOW:
+				out.Allowed, out.Policy, out.Rule = true, name, rule
+				return out, nil
+			}
+		}
+	}
+	out.Allowed = allowPolicies == 0
+	return out, nil
+}
+
+// appliesTo returns true when the policy applies to the workload, see Selects.
+func appliesTo(policy *securityv1.AuthorizationPolicy, workload parser.Workload) bool {
+	return Selects(policy.Namespace, policy.Spec.GetSelector().GetMatchLabels(), workload)
+}
+
+// Selects returns true when a security policy of the namespace with the selector applies to the workload:
+// policies of the root namespace apply to the workloads they select in any namespace, all of them without
+// selector, the other ones to the workloads of their namespace they select.
+func Selects(namespace string, selector map[string]string, workload parser.Workload) bool {
+	if namespace != RootNamespace && namespace != workload.Namespace {
+		return false
+	}
+	for name, value := range selector {
+		if got, ok := workload.Labels[name]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPolicy returns the index of the first rule of the policy matching the request, or -1 when none does.
+// Policies without rules match no request.
+func matchPolicy(policy *securityv1.AuthorizationPolicy, request Request) (int, error) {
+	for i, rule := range policy.Spec.Rules {
+		match, err := matchRule(rule, request)
+		if err != nil {
+			return -1, fmt.Errorf("rule %d: %w", i, err)
+		}
+		if match {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// Match returns true when the decision is the expected one and, when set, was taken by the expected policy and
+// rule.
+func (d Decision) Match(want *parser.Authorization) bool {
+	if d.Allowed != want.Allowed {
+		return false
+	}
+	if want.Policy != "" && d.Policy != want.Policy {
+		return false
+	}
+	if want.Rule != nil && (d.Policy == "" || d.Rule != *want.Rule) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,187 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	security "istio.io/api/security/v1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	securityv1 "istio.io/client-go/pkg/apis/security/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEvaluate(t *testing.T) {
+	policy := func(namespace, name string, action security.AuthorizationPolicy_Action, selector map[string]string, rules ...*security.Rule) *securityv1.AuthorizationPolicy {
+		out := &securityv1.AuthorizationPolicy{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       security.AuthorizationPolicy{Action: action, Rules: rules},
+		}
+		if selector != nil {
+			out.Spec.Selector = &typev1beta1.WorkloadSelector{MatchLabels: selector}
+		}
+		return out
+	}
+	to := func(operation *security.Operation) *security.Rule {
+		return &security.Rule{To: []*security.Rule_To{{Operation: operation}}}
+	}
+	from := func(source *security.Source) *security.Rule {
+		return &security.Rule{From: []*security.Rule_From{{Source: source}}}
+	}
+	payments := map[string]string{"app": "payments"}
+	policies := []*securityv1.AuthorizationPolicy{
+		policy("istio-system", "ext-authz", security.AuthorizationPolicy_CUSTOM, nil, to(&security.Operation{Paths: []string{"/admin/*"}})),
+		policy("istio-system", "deny-debug", security.AuthorizationPolicy_DENY, nil, to(&security.Operation{Paths: []string{"/debug/*"}})),
+		policy("payments", "deny-delete", security.AuthorizationPolicy_DENY, payments, to(&security.Operation{Methods: []string{"DELETE"}})),
+		policy("payments", "allow-checkout", security.AuthorizationPolicy_ALLOW, payments,
+			from(&security.Source{Principals: []string{"cluster.local/ns/shop/sa/checkout"}}),
+			&security.Rule{
+				From: []*security.Rule_From{{Source: &security.Source{NotNamespaces: []string{"shop"}}}},
+				To:   []*security.Rule_To{{Operation: &security.Operation{Hosts: []string{"*.example.com"}, NotPaths: []string{"/internal/*"}}}},
+				When: []*security.Condition{{Key: "request.headers[X-Tenant]", Values: []string{"acme"}}},
+			},
+		),
+		policy("search", "allow-nothing", security.AuthorizationPolicy_ALLOW, nil),
+		policy("orders", "allow-authenticated", security.AuthorizationPolicy_ALLOW, nil,
+			from(&security.Source{RequestPrincipals: []string{"*"}, NotRequestPrincipals: []string{"https://auth.example.com/blocked"}}),
+		),
+	}
+	checkout := &parser.Source{Namespace: "shop", Principal: "cluster.local/ns/shop/sa/checkout"}
+	tests := []struct {
+		name    string
+		request Request
+		want    Decision
+		wantErr string
+	}{
+		{
+			name:    "allowed by principal",
+			request: Request{Input: parser.Input{Method: "POST", URI: "/v2/charges"}, Workload: parser.Workload{Namespace: "payments", Labels: payments}, Source: checkout},
+			want:    Decision{Allowed: true, Policy: "payments/allow-checkout", Rule: 0},
+		},
+		{
+			name:    "denied by method",
+			request: Request{Input: parser.Input{Method: "DELETE", URI: "/v2/charges"}, Workload: parser.Workload{Namespace: "payments", Labels: payments}, Source: checkout},
+			want:    Decision{Policy: "payments/deny-delete", Rule: 0},
+		},
+		{
+			name:    "denied by a root namespace policy",
+			request: Request{Input: parser.Input{Method: "GET", URI: "/debug/pprof"}, Workload: parser.Workload{Namespace: "payments", Labels: payments}, Source: checkout},
+			want:    Decision{Policy: "istio-system/deny-debug", Rule: 0},
+		},
+		{
+			name: "allowed by host and header from another namespace",
+			request: Request{
+				Input:    parser.Input{Authority: "Payments.Example.com:443", Method: "GET", URI: "/v2/charges", Headers: map[string]string{"x-tenant": "acme"}},
+				Workload: parser.Workload{Namespace: "payments", Labels: payments},
+				Source:   &parser.Source{Namespace: "backoffice"},
+			},
+			want: Decision{Allowed: true, Policy: "payments/allow-checkout", Rule: 1},
+		},
+		{
+			name: "no matching allow rule",
+			request: Request{
+				Input:    parser.Input{Authority: "payments.example.com", Method: "GET", URI: "/internal/stats", Headers: map[string]string{"x-tenant": "acme"}},
+				Workload: parser.Workload{Namespace: "payments", Labels: payments},
+				Source:   &parser.Source{Namespace: "backoffice"},
+			},
+			want: Decision{},
+		},
+		{
+			name:    "requests from outside the mesh are in no namespace",
+			request: Request{Input: parser.Input{Authority: "payments.example.com", Method: "GET", URI: "/", Headers: map[string]string{"x-tenant": "acme"}}, Workload: parser.Workload{Namespace: "payments", Labels: payments}},
+			want:    Decision{Allowed: true, Policy: "payments/allow-checkout", Rule: 1},
+		},
+		{
+			name:    "no allow policy",
+			request: Request{Input: parser.Input{Method: "GET", URI: "/"}, Workload: parser.Workload{Namespace: "shop"}},
+			want:    Decision{Allowed: true},
+		},
+		{
+			name:    "allow nothing",
+			request: Request{Input: parser.Input{Method: "GET", URI: "/"}, Workload: parser.Workload{Namespace: "search"}},
+			want:    Decision{},
+		},
+		{
+			name:    "unauthenticated request",
+			request: Request{Input: parser.Input{Method: "GET", URI: "/"}, Workload: parser.Workload{Namespace: "orders"}},
+			want:    Decision{},
+		},
+		{
+			name:    "authenticated request",
+			request: Request{Input: parser.Input{Method: "GET", URI: "/", Claims: map[string]any{"iss": "https://auth.example.com", "sub": "42"}}, Workload: parser.Workload{Namespace: "orders"}},
+			want:    Decision{Allowed: true, Policy: "orders/allow-authenticated", Rule: 0},
+		},
+		{
+			name:    "excluded request principal",
+			request: Request{Input: parser.Input{Method: "GET", URI: "/", Claims: map[string]any{"iss": "https://auth.example.com", "sub": "blocked"}}, Workload: parser.Workload{Namespace: "orders"}},
+			want:    Decision{},
+		},
+		{
+			name:    "custom policy",
+			request: Request{Input: parser.Input{Method: "GET", URI: "/admin/users"}, Workload: parser.Workload{Namespace: "shop"}},
+			want:    Decision{Allowed: true, Custom: "istio-system/ext-authz"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Evaluate(policies, tt.request)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSelects(t *testing.T) {
+	payments := map[string]string{"app": "payments"}
+	tests := []struct {
+		name      string
+		namespace string
+		selector  map[string]string
+		workload  parser.Workload
+		want      bool
+	}{
+		{name: "root namespace without selector", namespace: RootNamespace, workload: parser.Workload{Namespace: "shop"}, want: true},
+		{name: "root namespace selector in another namespace", namespace: RootNamespace, selector: payments, workload: parser.Workload{Namespace: "payments", Labels: payments}, want: true},
+		{name: "root namespace selector not matching", namespace: RootNamespace, selector: payments, workload: parser.Workload{Namespace: "shop"}, want: false},
+		{name: "namespace without selector", namespace: "payments", workload: parser.Workload{Namespace: "payments"}, want: true},
+		{name: "selector in its namespace", namespace: "payments", selector: payments, workload: parser.Workload{Namespace: "payments", Labels: payments}, want: true},
+		{name: "selector in another namespace", namespace: "payments", selector: payments, workload: parser.Workload{Namespace: "billing", Labels: payments}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, Selects(tt.namespace, tt.selector, tt.workload))
+		})
+	}
+}
+
+func TestEvaluateEmptyEntries(t *testing.T) {
+	policies := []*securityv1.AuthorizationPolicy{{
+		ObjectMeta: metav1.ObjectMeta{Name: "allow-any", Namespace: "shop"},
+		Spec: security.AuthorizationPolicy{Rules: []*security.Rule{{
+			From: []*security.Rule_From{{}},
+			To:   []*security.Rule_To{{}},
+		}}},
+	}}
+	got, err := Evaluate(policies, Request{Input: parser.Input{Method: "GET", URI: "/"}, Workload: parser.Workload{Namespace: "shop"}})
+	require.NoError(t, err)
+	require.Equal(t, Decision{Allowed: true, Policy: "shop/allow-any", Rule: 0}, got)
+}
+
+func TestEvaluateUnsupported(t *testing.T) {
+	policies := []*securityv1.AuthorizationPolicy{{
+		ObjectMeta: metav1.ObjectMeta{Name: "ip", Namespace: "shop"},
+		Spec: security.AuthorizationPolicy{Rules: []*security.Rule{{
+			When: []*security.Condition{{Key: "source.ip", Values: []string{"10.0.0.1"}}},
+		}}},
+	}}
+	_, err := Evaluate(policies, Request{Workload: parser.Workload{Namespace: "shop"}})
+	require.ErrorContains(t, err, `evaluating authorizationpolicy shop/ip failed: rule 0: condition key "source.ip" is not supported`)
+}
+
+func TestMatchPattern(t *testing.T) {
+	require.True(t, matchPattern("/v2/charges", "*"))
+	require.True(t, matchPattern("/v2/charges", "/v2/*"))
+	require.True(t, matchPattern("api.example.com", "*.example.com"))
+	require.False(t, matchPattern("/v3/charges", "/v2/*"))
+	require.False(t, matchPattern("", "*"))
+}
@@ -0,0 +1,157 @@
+package authz
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	security "istio.io/api/security/v1"
+)
+
+// matchRule returns true when the request matches one of the sources, one of the operations and all the
+// conditions of the rule. Missing sources or operations match any request.
+func matchRule(rule *security.Rule, request Request) (bool, error) {
+	if len(rule.From) > 0 {
+		match := false
+		for _, from := range rule.From {
+			ok, err := matchSource(from.GetSource(), request)
+			if err != nil {
+				return false, err
+			}
+			match = match || ok
+		}
+		if !match {
+			return false, nil
+		}
+	}
+	if len(rule.To) > 0 && !slices.ContainsFunc(rule.To, func(to *security.Rule_To) bool { return matchOperation(to.GetOperation(), request) }) {
+		return false, nil
+	}
+	for _, condition := range rule.When {
+		match, err := matchCondition(condition, request)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// matchSource returns true when the source of the request matches all the fields set in the source, any source
+// when it is empty. Requests from outside the mesh have no principal nor namespace.
+func matchSource(source *security.Source, request Request) (bool, error) {
+	if len(source.GetIpBlocks()) > 0 || len(source.GetNotIpBlocks()) > 0 || len(source.GetRemoteIpBlocks()) > 0 || len(source.GetNotRemoteIpBlocks()) > 0 {
+		return false, fmt.Errorf("ip blocks are not supported")
+	}
+	principal, namespace := sourceIdentity(request)
+	serviceAccount := ""
+	if _, sa, ok := strings.Cut(principal, "/sa/"); ok {
+		serviceAccount = namespace + "/" + sa
+	}
+	return matchValues(principal, source.GetPrincipals(), source.GetNotPrincipals()) &&
+		matchValues(requestPrincipal(request), source.GetRequestPrincipals(), source.GetNotRequestPrincipals()) &&
+		matchValues(namespace, source.GetNamespaces(), source.GetNotNamespaces()) &&
+		matchValues(serviceAccount, source.GetServiceAccounts(), source.GetNotServiceAccounts()), nil
+}
+
+// sourceIdentity returns the principal and namespace of the workload sending the request. The principal
+// defaults to the one of the default service account of the namespace.
+func sourceIdentity(request Request) (string, string) {
+	if request.Source == nil {
+		return "", ""
+	}
+	principal := request.Source.Principal
+	if principal == "" && request.Source.Namespace != "" {
+		principal = "cluster.local/ns/" + request.Source.Namespace + "/sa/default"
+	}
+	return principal, request.Source.Namespace
+}
+
+// requestPrincipal returns the principal of the token of the request, its issuer and subject separated by "/",
+// empty when the request has no validated token.
+func requestPrincipal(request Request) string {
+	issuer, _ := request.Claims["iss"].(string)
+	subject, _ := request.Claims["sub"].(string)
+	if issuer == "" || subject == "" {
+		return ""
+	}
+	return issuer + "/" + subject
+}
+
+// matchOperation returns true when the request matches all the fields set in the operation, any request when it
+// is empty.
+func matchOperation(operation *security.Operation, request Request) bool {
+	host, _, _ := strings.Cut(strings.ToLower(request.Authority), ":")
+	hosts := lower(operation.GetHosts())
+	notHosts := lower(operation.GetNotHosts())
+	port := ""
+	if request.Port != 0 {
+		port = strconv.Itoa(int(request.Port))
+	}
+	return matchValues(host, hosts, notHosts) &&
+		matchValues(port, operation.GetPorts(), operation.GetNotPorts()) &&
+		matchValues(request.Method, operation.GetMethods(), operation.GetNotMethods()) &&
+		matchValues(request.URI, operation.GetPaths(), operation.GetNotPaths())
+}
+
+// matchCondition returns true when the value of the condition key for the request is one of the values, and
+// not one of the notValues.
+func matchCondition(condition *security.Condition, request Request) (bool, error) {
+	principal, namespace := sourceIdentity(request)
+	var value string
+	switch key := condition.Key; {
+	case strings.HasPrefix(key, "request.headers[") && strings.HasSuffix(key, "]"):
+		name := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(key, "request.headers["), "]"))
+		for header, headerValue := range request.Headers {
+			if strings.ToLower(header) == name {
+				value = headerValue
+			}
+		}
+	case key == "source.principal":
+		value = principal
+	case key == "source.namespace":
+		value = namespace
+	case key == "destination.port":
+		if request.Port != 0 {
+			value = strconv.Itoa(int(request.Port))
+		}
+	default:
+		return false, fmt.Errorf("condition key %q is not supported", key)
+	}
+	return matchValues(value, condition.Values, condition.NotValues), nil
+}
+
+// matchValues returns true when the value matches one of the values, when there are some, and none of the
+// notValues. Empty values match no pattern.
+func matchValues(value string, values, notValues []string) bool {
+	match := func(pattern string) bool { return matchPattern(value, pattern) }
+	if len(values) > 0 && !slices.ContainsFunc(values, match) {
+		return false
+	}
+	return !slices.ContainsFunc(notValues, match)
+}
+
+// matchPattern returns true when the value matches the pattern: "*" matches any non empty value, "abc*" a
+// prefix, "*abc" a suffix and other patterns the exact value.
+func matchPattern(value, pattern string) bool {
+	if value == "" {
+		return false
+	}
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasSuffix(pattern, "*"):
+		return strings.HasPrefix(value, strings.TrimSuffix(pattern, "*"))
+	case strings.HasPrefix(pattern, "*"):
+		return strings.HasSuffix(value, strings.TrimPrefix(pattern, "*"))
+	}
+	return value == pattern
+}
+
+func lower(values []string) []string {
+	var out []string
+	for _, value := range values {
+		out = append(out, strings.ToLower(value))
+	}
+	return out
+}
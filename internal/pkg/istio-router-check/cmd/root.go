@@ -54,8 +54,8 @@ func NewCmdRoot() (*cobra.Command, error) {
 	}
 
 	cmd.Flags().IntVarP(&rootCmd.Verbosity, "", "v", LevelInfo, "Log verbosity level")
-	cmd.Flags().StringVarP(&rootCmd.Gateway, "gateway", "", "", "Only consider VirtualServices bound to this gateway (i.e: istio-system/istio-ingressgateway)")
-	cmd.Flags().StringVarP(&rootCmd.ConfigDir, "config-dir", "c", "", "Directory with Istio VirtualService and Gateway files")
+	cmd.Flags().StringVarP(&rootCmd.Gateway, "gateway", "", "", "Only consider VirtualServices bound to this gateway, or HTTPRoutes attached to this Gateway API gateway (i.e: istio-system/istio-ingressgateway)")
+	cmd.Flags().StringVarP(&rootCmd.ConfigDir, "config-dir", "c", "", "Directory with Istio VirtualService and Gateway files, or Gateway API Gateway and HTTPRoute files")
 	cmd.Flags().StringVarP(&rootCmd.TestDir, "test-dir", "t", "", "Directory with Envoy test files")
 	cmd.Flags().BoolVarP(&rootCmd.ConvertTests, "convert-tests", "", false, "Convert istio-config-validator tests into Envoy tests")
 	cmd.Flags().StringVarP(&rootCmd.OutputDir, "output-dir", "o", "", "Directory to output Envoy routes and tests")
@@ -179,7 +179,7 @@ func (c *RootCommand) prepareTests(ctx context.Context) error {
 	}
 	var newTests envoy.Tests
 	for _, tc := range testCases {
-		inputs, err := tc.Request.Unfold()
+		inputs, err := tc.Inputs()
 		if err != nil {
 			return fmt.Errorf("could not unfold request: %w", err)
 		}
@@ -196,6 +196,10 @@ func (c *RootCommand) prepareTests(ctx context.Context) error {
 			continue
 		}
 		for _, req := range inputs {
+			if req.Protocol == parser.ProtocolTLS || req.Protocol == parser.ProtocolTCP {
+				log.V(LevelDebug).Info("skipping request", "test", tc.Description, "reason", "router_check_tool only supports http routes")
+				continue
+			}
 			var reqHeaders []envoy.Header
 			for key, value := range req.Headers {
 				reqHeaders = append(reqHeaders, envoy.Header{Key: key, Value: value})
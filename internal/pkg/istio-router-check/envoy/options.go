@@ -13,8 +13,8 @@ func WithConfigs(cfgs []config.Config) optionFunc {
 	}
 }
 
-// WithGateway generate routes with the provided gateway view. If the gateway does not exist in the provided configs,
-// it will be created with the default values accepting "*" as hosts.
+// WithGateway generate routes with the provided gateway view, an Istio Gateway or a Gateway API Gateway. If the
+// gateway does not exist in the provided configs, it will be created with the default values accepting "*" as hosts.
 func WithGateway(name string) optionFunc {
 	return func(rg *routeGenerator) {
 		rg.gatewayName = name
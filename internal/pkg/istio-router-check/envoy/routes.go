@@ -3,6 +3,7 @@ package envoy
 import (
 	"fmt"
 	"os"
+	"slices"
 
 	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
 	"github.com/getyourguide/istio-config-validator/internal/pkg/istio-router-check/helpers"
@@ -13,11 +14,22 @@ import (
 	"istio.io/istio/pilot/test/xds"
 	"istio.io/istio/pkg/config"
 	"istio.io/istio/pkg/config/schema/gvk"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
 
 	istiolog "istio.io/istio/pkg/log"
 	istiotest "istio.io/istio/pkg/test"
 )
 
+const (
+	// istioGatewayController is the controller name of the Gateway API gateway classes istio implements.
+	istioGatewayController = "istio.io/gateway-controller"
+	// gatewayNameLabel labels the pods of the deployments istio generates for Gateway API gateways.
+	gatewayNameLabel = "gateway.networking.k8s.io/gateway-name"
+)
+
+// istioGatewayClasses are the Gateway API gateway classes istio creates in the clusters it is installed in.
+var istioGatewayClasses = []string{"istio", "istio-remote", "istio-waypoint", "istio-east-west"}
+
 type routeGenerator struct {
 	configs     []config.Config
 	proxy       *model.Proxy
@@ -86,8 +98,9 @@ func (rg *routeGenerator) Routes() ([]*route.RouteConfiguration, error) {
 }
 
 // prepareProxy creates a proxy with the provided metadata. If the gateway is set, it will create a proxy with the
-// metadata of the gateway. If the gateway does not exist in the provided configs, it will be created with the default
-// values accepting "*" as hosts.
+// metadata of the gateway. Gateway API gateways are served by the deployment istio generates for them, whose pods
+// are labeled with the gateway name. If the gateway does not exist in the provided configs, it will be created with
+// the default values accepting "*" as hosts.
 func (rg *routeGenerator) prepareProxy() error {
 	if rg.gatewayName == "" {
 		return nil
@@ -102,10 +115,11 @@ func (rg *routeGenerator) prepareProxy() error {
 	}
 	var gatewayFound bool
 	for _, cfg := range rg.configs {
-		if cfg.GroupVersionKind != gvk.Gateway {
+		if cfg.Name != namespacedName.Name || cfg.Namespace != namespacedName.Namespace {
 			continue
 		}
-		if cfg.Name == namespacedName.Name && cfg.Namespace == namespacedName.Namespace {
+		switch cfg.GroupVersionKind {
+		case gvk.Gateway:
 			gatewayFound = true
 			var selector map[string]string
 			switch v := cfg.Spec.(type) {
@@ -119,8 +133,24 @@ func (rg *routeGenerator) prepareProxy() error {
 				Namespace: cfg.Namespace,
 				Labels:    selector,
 			}
-			break
+		case gvk.KubernetesGateway:
+			gatewayFound = true
+			spec, ok := cfg.Spec.(*gatewayv1.GatewaySpec)
+			if !ok {
+				return fmt.Errorf("could not cast Gateway API Gateway spec (%T) for %s/%s", cfg.Spec, cfg.Namespace, cfg.Name)
+			}
+			rg.ensureGatewayClass(string(spec.GatewayClassName))
+
+			metadata = &model.NodeMetadata{
+				Namespace: cfg.Namespace,
+				Labels: map[string]string{
+					gatewayNameLabel: cfg.Name,
+				},
+			}
+		default:
+			continue
 		}
+		break
 	}
 
 	if !gatewayFound {
@@ -153,6 +183,30 @@ func (rg *routeGenerator) prepareProxy() error {
 	return nil
 }
 
+// ensureGatewayClass adds the gateway class of a Gateway API gateway, controlled by istio, when it is one of the
+// istioGatewayClasses and the configs do not define it. Istio only converts the gateways of its classes, which a
+// cluster defines but configs rarely do. Gateways of other classes are left to their controller, with a warning.
+func (rg *routeGenerator) ensureGatewayClass(name string) {
+	for _, cfg := range rg.configs {
+		if cfg.GroupVersionKind == gvk.GatewayClass && cfg.Name == name {
+			return
+		}
+	}
+	if !slices.Contains(istioGatewayClasses, name) {
+		fmt.Fprintf(os.Stderr, "WARN gateway class %q is not defined and not an istio one, its gateways get no routes\n", name)
+		return
+	}
+	rg.configs = append(rg.configs, config.Config{
+		Meta: config.Meta{
+			GroupVersionKind: gvk.GatewayClass,
+			Name:             name,
+		},
+		Spec: &gatewayv1.GatewayClassSpec{
+			ControllerName: istioGatewayController,
+		},
+	})
+}
+
 func ReadCRDs(baseDir string) ([]config.Config, error) {
 	var configs []config.Config
 	yamlFiles, err := helpers.WalkYAML(baseDir)
@@ -20,6 +20,33 @@ func TestRoutesGenerator(t *testing.T) {
 		require.NoError(t, err)
 		require.Len(t, routes, 1)
 	})
+	t.Run("it should generate routes for a gateway api gateway", func(t *testing.T) {
+		cfg, err := envoy.ReadCRDs("testdata/httproute.yml")
+		require.NoError(t, err)
+		rg := envoy.NewRouteGenerator(
+			envoy.WithConfigs(cfg),
+			envoy.WithGateway("ingress/travel"),
+		)
+		routes, err := rg.Routes()
+		require.NoError(t, err)
+		require.Len(t, routes, 1)
+		require.Contains(t, routes[0].GetVirtualHosts()[0].GetDomains(), "www.travel.example.com")
+	})
+	t.Run("it should not route gateways of other classes", func(t *testing.T) {
+		cfg, err := envoy.ReadCRDs("testdata/httproute_other_class.yml")
+		require.NoError(t, err)
+		rg := envoy.NewRouteGenerator(
+			envoy.WithConfigs(cfg),
+			envoy.WithGateway("ingress/travel"),
+		)
+		routes, err := rg.Routes()
+		require.NoError(t, err)
+		for _, route := range routes {
+			for _, virtualHost := range route.GetVirtualHosts() {
+				require.NotContains(t, virtualHost.GetDomains(), "www.travel.example.com")
+			}
+		}
+	})
 }
 
 func TestReadCRDs(t *testing.T) {
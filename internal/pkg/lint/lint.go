@@ -0,0 +1,301 @@
+// Package lint looks for istio configuration which is valid but most likely does not behave as intended.
+package lint
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"slices"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+)
+
+// Warning is a finding about a single resource.
+type Warning struct {
+	// Resource identifies the resource, as kind/namespace/name.
+	Resource string
+	Message  string
+}
+
+func (w Warning) String() string {
+	return w.Resource + ": " + w.Message
+}
+
+// VirtualServices returns the warnings found in the given virtualservices.
+func VirtualServices(virtualServices []*v1.VirtualService) []Warning {
+	var out []Warning
+	for _, vs := range virtualServices {
+		resource := fmt.Sprintf("virtualservice/%s/%s", vs.Namespace, vs.Name)
+		for i, httpRoute := range vs.Spec.Http {
+			for _, message := range weightWarnings(httpRoute.Route) {
+				out = append(out, Warning{Resource: resource, Message: routeName(i, httpRoute) + ": " + message})
+			}
+			for j, matchBlock := range httpRoute.Match {
+				location := fmt.Sprintf("%s match[%d]", routeName(i, httpRoute), j)
+				for _, message := range cookieMatchWarnings(matchBlock) {
+					out = append(out, Warning{Resource: resource, Message: location + ": " + message})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// DestinationRules returns the warnings about destinations of the virtualservices whose subset is not defined,
+// either because no destinationrule applies to their host or because the one applying does not define it.
+func DestinationRules(config *parser.Config) []Warning {
+	var out []Warning
+	for _, vs := range config.VirtualServices {
+		resource := fmt.Sprintf("virtualservice/%s/%s", vs.Namespace, vs.Name)
+		for _, destination := range routeDestinations(vs) {
+			if message := subsetWarning(config, vs.Namespace, destination.Destination); message != "" {
+				out = append(out, Warning{Resource: resource, Message: destination.location + ": " + message})
+			}
+		}
+	}
+	return out
+}
+
+// Destinations returns the warnings about destinations of the virtualservices which do not resolve to a
+// kubernetes service or service entry, or to one of their ports.
+func Destinations(config *parser.Config) []Warning {
+	var out []Warning
+	for _, vs := range config.VirtualServices {
+		resource := fmt.Sprintf("virtualservice/%s/%s", vs.Namespace, vs.Name)
+		for _, destination := range routeDestinations(vs) {
+			if message := resolveWarning(config, vs.Namespace, destination.Destination); message != "" {
+				out = append(out, Warning{Resource: resource, Message: destination.location + ": " + message})
+			}
+		}
+	}
+	return out
+}
+
+// Ingresses returns the warnings about hosts of the ingresses served by istio which are also hosts of
+// virtualservices bound to a gateway. Both are served by the ingress gateways, which route the host with the
+// rules of only one of them.
+func Ingresses(config *parser.Config) []Warning {
+	var out []Warning
+	for _, ingress := range config.Ingresses {
+		if !parser.IsIstioIngress(ingress) {
+			continue
+		}
+		resource := fmt.Sprintf("ingress/%s/%s", ingress.Namespace, ingress.Name)
+		for _, rule := range ingress.Spec.Rules {
+			host := rule.Host
+			if host == "" {
+				host = "*"
+			}
+			for _, vs := range config.VirtualServices {
+				if !boundToGateway(vs) {
+					continue
+				}
+				conflicting := slices.ContainsFunc(vs.Spec.Hosts, func(vsHost string) bool {
+					return parser.MatchHost(host, vsHost) || parser.MatchHost(vsHost, host)
+				})
+				if conflicting {
+					out = append(out, Warning{Resource: resource, Message: fmt.Sprintf("host %q is also routed by virtualservice/%s/%s, requests are routed by only one of them", host, vs.Namespace, vs.Name)})
+				}
+			}
+		}
+	}
+	return out
+}
+
+// boundToGateway returns true when the virtualservice is bound to a gateway, and not only to the sidecars.
+func boundToGateway(vs *v1.VirtualService) bool {
+	return slices.ContainsFunc(vs.Spec.Gateways, func(gateway string) bool { return gateway != "mesh" })
+}
+
+type routeDestination struct {
+	location string
+	*networking.Destination
+}
+
+// routeDestinations returns the destinations of the routes and mirrors of the virtualservice, with the route
+// declaring them.
+func routeDestinations(vs *v1.VirtualService) []routeDestination {
+	var out []routeDestination
+	for i, httpRoute := range vs.Spec.Http {
+		location := routeName(i, httpRoute)
+		for _, destination := range httpRoute.Route {
+			out = append(out, routeDestination{location, destination.Destination})
+		}
+		if httpRoute.Mirror != nil {
+			out = append(out, routeDestination{location, httpRoute.Mirror})
+		}
+		for _, mirror := range httpRoute.Mirrors {
+			out = append(out, routeDestination{location, mirror.Destination})
+		}
+	}
+	for i, tlsRoute := range vs.Spec.Tls {
+		for _, destination := range tlsRoute.Route {
+			out = append(out, routeDestination{fmt.Sprintf("tls[%d]", i), destination.Destination})
+		}
+	}
+	for i, tcpRoute := range vs.Spec.Tcp {
+		for _, destination := range tcpRoute.Route {
+			out = append(out, routeDestination{fmt.Sprintf("tcp[%d]", i), destination.Destination})
+		}
+	}
+	return out
+}
+
+// subsetWarning reports a destination pointing at a subset no destinationrule defines. Traffic sent to an
+// undefined subset gets a 503.
+func subsetWarning(config *parser.Config, namespace string, destination *networking.Destination) string {
+	if destination.GetSubset() == "" {
+		return ""
+	}
+	dr := config.DestinationRule(destination.Host, namespace)
+	if dr == nil {
+		return fmt.Sprintf("destination %q uses subset %q but no destinationrule applies to the host", destination.Host, destination.Subset)
+	}
+	defined := slices.ContainsFunc(dr.Spec.Subsets, func(subset *networking.Subset) bool { return subset.Name == destination.Subset })
+	if !defined {
+		return fmt.Sprintf("destination %q uses subset %q which is not defined in destinationrule/%s/%s", destination.Host, destination.Subset, dr.Namespace, dr.Name)
+	}
+	return ""
+}
+
+// resolveWarning reports a destination whose host is not defined by any service, or whose port is not one of
+// the service ports. Services with several ports require the destination to select one.
+func resolveWarning(config *parser.Config, namespace string, destination *networking.Destination) string {
+	if destination == nil {
+		return ""
+	}
+	ports, ok := config.ServicePorts(destination.Host, namespace)
+	if !ok {
+		return fmt.Sprintf("destination %q does not resolve to a service or service entry (%s)", destination.Host, parser.FQDN(destination.Host, namespace))
+	}
+	port := destination.GetPort().GetNumber()
+	if port == 0 {
+		if len(ports) > 1 {
+			return fmt.Sprintf("destination %q has no port but its service has several: %v", destination.Host, ports)
+		}
+		return ""
+	}
+	if !slices.Contains(ports, port) {
+		return fmt.Sprintf("destination %q port %d is not one of its service ports: %v", destination.Host, port, ports)
+	}
+	return ""
+}
+
+// routeName returns the name of the http route or, when it has none, its position.
+func routeName(i int, httpRoute *networking.HTTPRoute) string {
+	if httpRoute.Name != "" {
+		return fmt.Sprintf("http %q", httpRoute.Name)
+	}
+	return fmt.Sprintf("http[%d]", i)
+}
+
+// weightWarnings reports weighted destinations whose weights do not sum to 100 and destinations which, having
+// no weight, get no traffic. A single destination gets all the traffic whatever its weight.
+func weightWarnings(destinations []*networking.HTTPRouteDestination) []string {
+	if len(destinations) < 2 {
+		return nil
+	}
+	var out []string
+	var total int32
+	for _, destination := range destinations {
+		total += destination.Weight
+		if destination.Weight == 0 {
+			out = append(out, fmt.Sprintf("destination %q has no weight and gets no traffic", destination.GetDestination().GetHost()))
+		}
+	}
+	if total != 100 {
+		out = append(out, fmt.Sprintf("destination weights sum to %d, not 100", total))
+	}
+	return out
+}
+
+// cookieRegexWrapper wraps a cookie regex to match the cookie wherever it is in the cookie header, whose cookies
+// are separated by "; ".
+const cookieRegexWrapper = `^(.*?;\s*)?(%s)(;.*)?$`
+
+// cookieMatchWarnings reports cookie header regexes that only match requests with no other cookie. Envoy
+// matches regexes against the whole header value, and the cookie header holds all the cookies of the request.
+func cookieMatchWarnings(matchBlock *networking.HTTPMatchRequest) []string {
+	var out []string
+	for _, field := range []string{"headers", "withoutHeaders"} {
+		headers := matchBlock.Headers
+		if field == "withoutHeaders" {
+			headers = matchBlock.WithoutHeaders
+		}
+		for name, sm := range headers {
+			if !strings.EqualFold(name, "cookie") || sm.GetRegex() == "" {
+				continue
+			}
+			re, err := syntax.Parse(sm.GetRegex(), syntax.Perl)
+			if err != nil {
+				// Invalid regexes fail the tests matching against them.
+				continue
+			}
+			re = re.Simplify()
+			if !openEnded(re, true) || !openEnded(re, false) {
+				wrapped := fmt.Sprintf(cookieRegexWrapper, sm.GetRegex())
+				out = append(out, fmt.Sprintf("%s.%s regex %q must match the whole cookie header, wrap it like %q to allow other cookies", field, name, sm.GetRegex(), wrapped))
+			}
+		}
+	}
+	return out
+}
+
+// openEnded returns true when the regex may start (or end) with arbitrary text, e.g. ".*" or "(.*;)?".
+func openEnded(re *syntax.Regexp, start bool) bool {
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	switch re.Op {
+	case syntax.OpConcat:
+		subs := re.Sub
+		for len(subs) > 0 {
+			i := len(subs) - 1
+			if start {
+				i = 0
+			}
+			if !isAnchor(subs[i]) {
+				return openEnded(subs[i], start)
+			}
+			if start {
+				subs = subs[1:]
+			} else {
+				subs = subs[:len(subs)-1]
+			}
+		}
+		return false
+	case syntax.OpAlternate:
+		for _, sub := range re.Sub {
+			if !openEnded(sub, start) {
+				return false
+			}
+		}
+		return true
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		return matchesAnyChar(re.Sub[0])
+	}
+	return false
+}
+
+func isAnchor(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpBeginText, syntax.OpEndText, syntax.OpBeginLine, syntax.OpEndLine:
+		return true
+	}
+	return false
+}
+
+// matchesAnyChar returns true when the regex has a wildcard, able to consume other cookies.
+func matchesAnyChar(re *syntax.Regexp) bool {
+	if re.Op == syntax.OpAnyChar || re.Op == syntax.OpAnyCharNotNL {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if matchesAnyChar(sub) {
+			return true
+		}
+	}
+	return false
+}
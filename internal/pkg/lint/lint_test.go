@@ -0,0 +1,308 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	corev1 "k8s.io/api/core/v1"
+	knetworking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestVirtualServicesCookieRegex(t *testing.T) {
+	tests := []struct {
+		name  string
+		regex string
+		want  []string
+	}{
+		{
+			name:  "bare cookie",
+			regex: "user=qa",
+			want:  []string{`virtualservice/example/example: http "users" match[0]: headers.cookie regex "user=qa" must match the whole cookie header, wrap it like "^(.*?;\\s*)?(user=qa)(;.*)?$" to allow other cookies`},
+		},
+		{
+			name:  "open at the start only",
+			regex: ".*user=qa",
+			want:  []string{`virtualservice/example/example: http "users" match[0]: headers.cookie regex ".*user=qa" must match the whole cookie header, wrap it like "^(.*?;\\s*)?(.*user=qa)(;.*)?$" to allow other cookies`},
+		},
+		{
+			name:  "wildcards",
+			regex: ".*user=qa.*",
+		},
+		{
+			name:  "anchored optional cookies",
+			regex: `^(.*?;\s*)?(user=qa)(;.*)?$`,
+		},
+		{
+			name:  "alternatives",
+			regex: "(.*;)?user=(qa|beta)(;.*)?|.*debug=1.*",
+		},
+		{
+			name:  "invalid regex",
+			regex: "user=(qa",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := &v1.VirtualService{
+				ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "example"},
+				Spec: networking.VirtualService{
+					Http: []*networking.HTTPRoute{{
+						Name: "users",
+						Match: []*networking.HTTPMatchRequest{{
+							Headers: map[string]*networking.StringMatch{
+								"cookie": {MatchType: &networking.StringMatch_Regex{Regex: tt.regex}},
+							},
+						}},
+					}},
+				},
+			}
+			var got []string
+			for _, warning := range VirtualServices([]*v1.VirtualService{vs}) {
+				got = append(got, warning.String())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCookieRegexWrapper(t *testing.T) {
+	re := regexp.MustCompile(fmt.Sprintf(cookieRegexWrapper, "checkout=v2"))
+	require.True(t, re.MatchString("checkout=v2"))
+	require.True(t, re.MatchString("checkout=v2; lang=en"))
+	require.True(t, re.MatchString("lang=en; checkout=v2"))
+	require.True(t, re.MatchString("lang=en;checkout=v2; session=abc"))
+	require.False(t, re.MatchString("lang=en; checkout=v20"))
+	require.False(t, re.MatchString("lang=en; xcheckout=v2"))
+}
+
+func TestVirtualServicesWeights(t *testing.T) {
+	destination := func(host string, weight int32) *networking.HTTPRouteDestination {
+		return &networking.HTTPRouteDestination{Destination: &networking.Destination{Host: host}, Weight: weight}
+	}
+	tests := []struct {
+		name  string
+		route []*networking.HTTPRouteDestination
+		want  []string
+	}{
+		{
+			name:  "single destination without weight",
+			route: []*networking.HTTPRouteDestination{destination("users", 0)},
+		},
+		{
+			name:  "weights sum to 100",
+			route: []*networking.HTTPRouteDestination{destination("users", 90), destination("users-canary", 10)},
+		},
+		{
+			name:  "weights do not sum to 100",
+			route: []*networking.HTTPRouteDestination{destination("users", 90), destination("users-canary", 20)},
+			want:  []string{"virtualservice/example/example: http[0]: destination weights sum to 110, not 100"},
+		},
+		{
+			name:  "zero weight",
+			route: []*networking.HTTPRouteDestination{destination("users", 100), destination("users-canary", 0)},
+			want:  []string{`virtualservice/example/example: http[0]: destination "users-canary" has no weight and gets no traffic`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := &v1.VirtualService{
+				ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "example"},
+				Spec: networking.VirtualService{
+					Http: []*networking.HTTPRoute{{Route: tt.route}},
+				},
+			}
+			var got []string
+			for _, warning := range VirtualServices([]*v1.VirtualService{vs}) {
+				got = append(got, warning.String())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDestinationRules(t *testing.T) {
+	destinationRule := &v1.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "reviews"},
+		Spec: networking.DestinationRule{
+			Host:    "reviews",
+			Subsets: []*networking.Subset{{Name: "v1"}},
+		},
+	}
+	tests := []struct {
+		name        string
+		destination *networking.Destination
+		want        []string
+	}{
+		{
+			name:        "no subset",
+			destination: &networking.Destination{Host: "ratings.ratings.svc.cluster.local"},
+		},
+		{
+			name:        "defined subset",
+			destination: &networking.Destination{Host: "reviews.reviews.svc.cluster.local", Subset: "v1"},
+		},
+		{
+			name:        "undefined subset",
+			destination: &networking.Destination{Host: "reviews.reviews.svc.cluster.local", Subset: "v2"},
+			want:        []string{`virtualservice/example/example: http[0]: destination "reviews.reviews.svc.cluster.local" uses subset "v2" which is not defined in destinationrule/reviews/reviews`},
+		},
+		{
+			name:        "short name in another namespace",
+			destination: &networking.Destination{Host: "reviews", Subset: "v1"},
+			want:        []string{`virtualservice/example/example: http[0]: destination "reviews" uses subset "v1" but no destinationrule applies to the host`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := &v1.VirtualService{
+				ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "example"},
+				Spec: networking.VirtualService{
+					Http: []*networking.HTTPRoute{{Route: []*networking.HTTPRouteDestination{{Destination: tt.destination}}}},
+				},
+			}
+			config := &parser.Config{
+				VirtualServices:  []*v1.VirtualService{vs},
+				DestinationRules: []*v1.DestinationRule{destinationRule},
+			}
+			var got []string
+			for _, warning := range DestinationRules(config) {
+				got = append(got, warning.String())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDestinations(t *testing.T) {
+	config := &parser.Config{
+		Services: []*corev1.Service{{
+			ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "reviews"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 9080}, {Port: 9090}}},
+		}, {
+			ObjectMeta: metav1.ObjectMeta{Name: "ratings", Namespace: "example"},
+			Spec:       corev1.ServiceSpec{Ports: []corev1.ServicePort{{Port: 80}}},
+		}},
+		ServiceEntries: []*v1.ServiceEntry{{
+			ObjectMeta: metav1.ObjectMeta{Name: "external", Namespace: "example"},
+			Spec: networking.ServiceEntry{
+				Hosts: []string{"*.example.com"},
+				Ports: []*networking.ServicePort{{Number: 443}},
+			},
+		}},
+	}
+	tests := []struct {
+		name        string
+		destination *networking.Destination
+		want        []string
+	}{
+		{
+			name:        "service port",
+			destination: &networking.Destination{Host: "reviews.reviews.svc.cluster.local", Port: &networking.PortSelector{Number: 9080}},
+		},
+		{
+			name:        "short name of a single port service",
+			destination: &networking.Destination{Host: "ratings"},
+		},
+		{
+			name:        "service entry",
+			destination: &networking.Destination{Host: "api.example.com", Port: &networking.PortSelector{Number: 443}},
+		},
+		{
+			name:        "unknown host",
+			destination: &networking.Destination{Host: "review"},
+			want:        []string{`virtualservice/example/example: http[0]: destination "review" does not resolve to a service or service entry (review.example.svc.cluster.local)`},
+		},
+		{
+			name:        "unknown port",
+			destination: &networking.Destination{Host: "api.example.com", Port: &networking.PortSelector{Number: 80}},
+			want:        []string{`virtualservice/example/example: http[0]: destination "api.example.com" port 80 is not one of its service ports: [443]`},
+		},
+		{
+			name:        "no port for a multiple port service",
+			destination: &networking.Destination{Host: "reviews.reviews.svc.cluster.local"},
+			want:        []string{`virtualservice/example/example: http[0]: destination "reviews.reviews.svc.cluster.local" has no port but its service has several: [9080 9090]`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.VirtualServices = []*v1.VirtualService{{
+				ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "example"},
+				Spec: networking.VirtualService{
+					Http: []*networking.HTTPRoute{{Route: []*networking.HTTPRouteDestination{{Destination: tt.destination}}}},
+				},
+			}}
+			var got []string
+			for _, warning := range Destinations(config) {
+				got = append(got, warning.String())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNamespacelessResources(t *testing.T) {
+	config, err := parser.ParseConfig([]string{"../parser/testdata/namespaceless.yml"})
+	require.NoError(t, err)
+	require.Empty(t, DestinationRules(config))
+	require.Empty(t, Destinations(config))
+}
+
+func TestIngresses(t *testing.T) {
+	class := parser.IngressClass
+	tests := []struct {
+		name     string
+		host     string
+		gateways []string
+		want     []string
+	}{
+		{
+			name:     "same host",
+			host:     "www.example.com",
+			gateways: []string{"istio-system/istio-ingressgateway"},
+			want:     []string{`ingress/legacy/legacy: host "www.example.com" is also routed by virtualservice/example/example, requests are routed by only one of them`},
+		},
+		{
+			name:     "wildcard host",
+			host:     "*.example.com",
+			gateways: []string{"istio-system/istio-ingressgateway"},
+			want:     []string{`ingress/legacy/legacy: host "www.example.com" is also routed by virtualservice/example/example, requests are routed by only one of them`},
+		},
+		{
+			name:     "other host",
+			host:     "api.example.com",
+			gateways: []string{"istio-system/istio-ingressgateway"},
+		},
+		{
+			name: "mesh virtualservice",
+			host: "www.example.com",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &parser.Config{
+				Ingresses: []*knetworking.Ingress{{
+					ObjectMeta: metav1.ObjectMeta{Name: "legacy", Namespace: "legacy"},
+					Spec: knetworking.IngressSpec{
+						IngressClassName: &class,
+						Rules:            []knetworking.IngressRule{{Host: "www.example.com"}},
+					},
+				}},
+				VirtualServices: []*v1.VirtualService{{
+					ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "example"},
+					Spec:       networking.VirtualService{Hosts: []string{tt.host}, Gateways: tt.gateways},
+				}},
+			}
+			var got []string
+			for _, warning := range Ingresses(config) {
+				got = append(got, warning.String())
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,209 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedAccessLogFormat indicates an access log line in neither of the supported formats
+var ErrUnsupportedAccessLogFormat = errors.New("unsupported access log format")
+
+// AccessLogEntry is a request read from an Envoy access log.
+type AccessLogEntry struct {
+	Input           Input
+	UpstreamCluster string
+	ResponseCode    int
+}
+
+// accessLogHeaderFields maps the request headers present in the default access log formats to their JSON keys.
+var accessLogHeaderFields = map[string]string{
+	"user-agent":      "user_agent",
+	"x-forwarded-for": "x_forwarded_for",
+	"x-request-id":    "request_id",
+}
+
+// ParseAccessLogs reads the requests logged by Envoy, one per line, either in the Istio or Envoy default text
+// format or in JSON. Only the given request headers are kept in the resulting inputs.
+func ParseAccessLogs(files []string, headers []string) ([]*AccessLogEntry, error) {
+	out := []*AccessLogEntry{}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading file %q failed: %w", file, err)
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		lineNumber := 0
+		for scanner.Scan() {
+			lineNumber++
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			entry, err := ParseAccessLogLine(line, headers)
+			if err != nil {
+				f.Close()
+				return nil, fmt.Errorf("parsing line %d of %q failed: %w", lineNumber, file, err)
+			}
+			out = append(out, entry)
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading file %q failed: %w", file, err)
+		}
+	}
+	return out, nil
+}
+
+// ParseAccessLogLine parses a single access log line. JSON lines are recognised by their leading brace.
+func ParseAccessLogLine(line string, headers []string) (*AccessLogEntry, error) {
+	if strings.HasPrefix(line, "{") {
+		return parseJSONAccessLog(line, headers)
+	}
+	return parseTextAccessLog(line, headers)
+}
+
+// parseTextAccessLog parses lines in the Istio default format
+//
+//	[%START_TIME%] "%REQ(:METHOD)% %REQ(X-ENVOY-ORIGINAL-PATH?:PATH)% %PROTOCOL%" %RESPONSE_CODE% %RESPONSE_FLAGS%
+//	%RESPONSE_CODE_DETAILS% %CONNECTION_TERMINATION_DETAILS% "%UPSTREAM_TRANSPORT_FAILURE_REASON%" %BYTES_RECEIVED%
+//	%BYTES_SENT% %DURATION% %RESP(X-ENVOY-UPSTREAM-SERVICE-TIME)% "%REQ(X-FORWARDED-FOR)%" "%REQ(USER-AGENT)%"
+//	"%REQ(X-REQUEST-ID)%" "%REQ(:AUTHORITY)%" "%UPSTREAM_HOST%" %UPSTREAM_CLUSTER_RAW% ...
+//
+// and in the shorter Envoy default format, which ends at %UPSTREAM_HOST% and has no upstream cluster.
+func parseTextAccessLog(line string, headers []string) (*AccessLogEntry, error) {
+	fields := splitAccessLogFields(line)
+
+	var entry AccessLogEntry
+	var headerFields map[string]int
+	switch {
+	case len(fields) >= 17:
+		headerFields = map[string]int{"x-forwarded-for": 11, "user-agent": 12, "x-request-id": 13}
+		entry.setAuthority(fields[14])
+		entry.UpstreamCluster = fields[16]
+	case len(fields) == 13:
+		headerFields = map[string]int{"x-forwarded-for": 8, "user-agent": 9, "x-request-id": 10}
+		entry.setAuthority(fields[11])
+	default:
+		return nil, fmt.Errorf("%w: %d fields", ErrUnsupportedAccessLogFormat, len(fields))
+	}
+
+	request := strings.Fields(fields[1])
+	if len(request) < 2 {
+		return nil, fmt.Errorf("%w: invalid request line %q", ErrUnsupportedAccessLogFormat, fields[1])
+	}
+	entry.Input.Method = request[0]
+	entry.setPath(request[1])
+	if code, err := strconv.Atoi(fields[2]); err == nil {
+		entry.ResponseCode = code
+	}
+	for _, name := range headers {
+		name = strings.ToLower(name)
+		if i, ok := headerFields[name]; ok {
+			entry.setHeader(name, fields[i])
+		}
+	}
+	if entry.UpstreamCluster == "-" {
+		entry.UpstreamCluster = ""
+	}
+	return &entry, nil
+}
+
+// parseJSONAccessLog parses lines in the Istio default JSON format. Selected headers are looked up by their
+// name, with dashes replaced by underscores.
+func parseJSONAccessLog(line string, headers []string) (*AccessLogEntry, error) {
+	fields := map[string]any{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupportedAccessLogFormat, err)
+	}
+	field := func(name string) string {
+		switch v := fields[name].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		return ""
+	}
+
+	var entry AccessLogEntry
+	entry.setAuthority(field("authority"))
+	entry.Input.Method = field("method")
+	entry.setPath(field("path"))
+	if code, err := strconv.Atoi(field("response_code")); err == nil {
+		entry.ResponseCode = code
+	}
+	entry.UpstreamCluster = field("upstream_cluster")
+	for _, name := range headers {
+		name = strings.ToLower(name)
+		key, ok := accessLogHeaderFields[name]
+		if !ok {
+			key = strings.ReplaceAll(name, "-", "_")
+		}
+		entry.setHeader(name, field(key))
+	}
+	return &entry, nil
+}
+
+// setAuthority sets the authority of the input. The port clients may send along with the host, e.g.
+// example.com:8080, becomes the port of the input, as virtualservice hosts never include it.
+func (e *AccessLogEntry) setAuthority(authority string) {
+	e.Input.Authority = authority
+	host, port, err := net.SplitHostPort(authority)
+	if err != nil {
+		return
+	}
+	if number, err := strconv.ParseUint(port, 10, 32); err == nil {
+		e.Input.Authority = host
+		e.Input.Port = uint32(number)
+	}
+}
+
+func (e *AccessLogEntry) setPath(path string) {
+	e.Input.URI, e.Input.Query = splitURI(path)
+}
+
+// setHeader adds the header to the input, skipping the values Envoy logs for missing headers.
+func (e *AccessLogEntry) setHeader(name, value string) {
+	if value == "" || value == "-" {
+		return
+	}
+	if e.Input.Headers == nil {
+		e.Input.Headers = map[string]string{}
+	}
+	e.Input.Headers[name] = value
+}
+
+// splitAccessLogFields splits a text access log line on spaces, keeping "quoted" and [bracketed] fields whole.
+func splitAccessLogFields(line string) []string {
+	var fields []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		var closing byte
+		switch line[0] {
+		case '"':
+			closing = '"'
+		case '[':
+			closing = ']'
+		}
+		if closing == 0 {
+			field, rest, _ := strings.Cut(line, " ")
+			fields = append(fields, field)
+			line = rest
+			continue
+		}
+		end := strings.IndexByte(line[1:], closing)
+		if end < 0 {
+			fields = append(fields, line[1:])
+			break
+		}
+		fields = append(fields, line[1:end+1])
+		line = line[end+2:]
+	}
+	return fields
+}
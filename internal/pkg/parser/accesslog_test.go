@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccessLogs(t *testing.T) {
+	entries, err := ParseAccessLogs([]string{"testdata/access.log"}, []string{"User-Agent"})
+	require.NoError(t, err)
+	require.Equal(t, []*AccessLogEntry{{
+		Input: Input{
+			Authority: "www.example.com",
+			Method:    "GET",
+			URI:       "/users/abc",
+			Headers:   map[string]string{"user-agent": "curl/7.73.0-DEV"},
+			Query:     map[string]string{"lang": "en"},
+		},
+		UpstreamCluster: "outbound|80||users.users.svc.cluster.local",
+		ResponseCode:    200,
+	}, {
+		Input: Input{
+			Authority: "example.com",
+			Method:    "POST",
+			URI:       "/partners",
+			Headers:   map[string]string{"user-agent": "Mozilla/5.0 (X11; Linux x86_64)"},
+		},
+		ResponseCode: 404,
+	}, {
+		Input: Input{
+			Authority: "example.com",
+			Method:    "GET",
+			URI:       "/partners/1",
+			Headers:   map[string]string{"user-agent": "curl/8.0"},
+		},
+		UpstreamCluster: "outbound|8000||partner.partner.svc.cluster.local",
+		ResponseCode:    200,
+	}}, entries)
+}
+
+func TestParseAccessLogLine(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		line    string
+		want    Input
+		wantErr error
+	}{{
+		name: "missing headers are skipped",
+		line: `[2020-11-25T21:26:18.409Z] "GET / HTTP/2" 200 - via_upstream - "-" 0 135 4 4 "-" "-" "-" "example.com" "10.44.1.27:80" outbound|80||monolith.monolith.svc.cluster.local - - - - -`,
+		want: Input{Authority: "example.com", Method: "GET", URI: "/"},
+	}, {
+		name: "authority with a port",
+		line: `[2020-11-25T21:26:18.409Z] "GET / HTTP/2" 200 - via_upstream - "-" 0 135 4 4 "-" "-" "-" "example.com:8080" "10.44.1.27:80" outbound|80||monolith.monolith.svc.cluster.local - - - - -`,
+		want: Input{Authority: "example.com", Method: "GET", URI: "/", Port: 8080},
+	}, {
+		name: "json authority with a port",
+		line: `{"authority":"[::1]:8443","method":"GET","path":"/"}`,
+		want: Input{Authority: "::1", Method: "GET", URI: "/", Port: 8443},
+	}, {
+		name:    "unknown format",
+		line:    `GET / 200`,
+		wantErr: ErrUnsupportedAccessLogFormat,
+	}, {
+		name:    "invalid json",
+		line:    `{"authority":`,
+		wantErr: ErrUnsupportedAccessLogFormat,
+	}} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseAccessLogLine(tt.line, []string{"user-agent", "x-request-id"})
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.Input)
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package parser
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	networking "istio.io/api/networking/v1"
+	security "istio.io/api/security/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	securityv1 "istio.io/client-go/pkg/apis/security/v1"
+	"istio.io/istio/pilot/pkg/config/kube/crd"
+	"istio.io/istio/pkg/config/schema/gvk"
+	corev1 "k8s.io/api/core/v1"
+	knetworking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+// Config holds the istio resources found in the config files.
+type Config struct {
+	VirtualServices  []*v1.VirtualService
+	DestinationRules []*v1.DestinationRule
+	ServiceEntries   []*v1.ServiceEntry
+	Gateways         []*v1.Gateway
+	Sidecars         []*v1.Sidecar
+
+	AuthorizationPolicies  []*securityv1.AuthorizationPolicy
+	RequestAuthentications []*securityv1.RequestAuthentication
+	// Services are the kubernetes services, used to check the destinations exist.
+	Services []*corev1.Service
+
+	// HTTPRoutes and KubernetesGateways are the Gateway API resources, which istio converts to virtualservices
+	// and gateways, see GatewayAPIVirtualServices and GatewayAPIGateways.
+	HTTPRoutes         []*gatewayv1.HTTPRoute
+	KubernetesGateways []*gatewayv1.Gateway
+	// Ingresses are the kubernetes ingresses, which istio converts to virtualservices and gateways, see
+	// IngressVirtualServices and IngressGateways.
+	Ingresses []*knetworking.Ingress
+}
+
+// DefaultNamespace is the namespace of the resources declared without one, as kubectl and istioctl apply them.
+const DefaultNamespace = "default"
+
+// ParseConfig parses the istio resources of the given files. Kinds which are not used by the tests are ignored.
+// Resources without namespace are in DefaultNamespace.
+func ParseConfig(files []string) (*Config, error) {
+	out := &Config{VirtualServices: []*v1.VirtualService{}}
+	for _, file := range files {
+		fileContent, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("reading file %q failed: %w", file, err)
+		}
+		configs, others, err := crd.ParseInputs(string(fileContent))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRD %q: %w", file, err)
+		}
+		for _, c := range configs {
+			switch c.GroupVersionKind {
+			case gvk.VirtualService:
+				spec, ok := c.Spec.(*networking.VirtualService)
+				if !ok {
+					return nil, fmt.Errorf("failed to convert spec in %q to VirtualService", file)
+				}
+				out.VirtualServices = append(out.VirtualServices, &v1.VirtualService{
+					ObjectMeta: withNamespace(c.ToObjectMeta()),
+					Spec:       *spec, //nolint as deep copying mess up with reflect.DeepEqual comparison.
+				})
+			case gvk.DestinationRule:
+				spec, ok := c.Spec.(*networking.DestinationRule)
+				if !ok {
+					return nil, fmt.Errorf("failed to convert spec in %q to DestinationRule", file)
+				}
+				rule := &v1.DestinationRule{ObjectMeta: withNamespace(c.ToObjectMeta())}
+				spec.DeepCopyInto(&rule.Spec)
+				out.DestinationRules = append(out.DestinationRules, rule)
+			case gvk.Gateway:
+				spec, ok := c.Spec.(*networking.Gateway)
+				if !ok {
+					return nil, fmt.Errorf("failed to convert spec in %q to Gateway", file)
+				}
+				gateway := &v1.Gateway{ObjectMeta: withNamespace(c.ToObjectMeta())}
+				spec.DeepCopyInto(&gateway.Spec)
+				out.Gateways = append(out.Gateways, gateway)
+			case gvk.Sidecar:
+				spec, ok := c.Spec.(*networking.Sidecar)
+				if !ok {
+					return nil, fmt.Errorf("failed to convert spec in %q to Sidecar", file)
+				}
+				sidecar := &v1.Sidecar{ObjectMeta: withNamespace(c.ToObjectMeta())}
+				spec.DeepCopyInto(&sidecar.Spec)
+				out.Sidecars = append(out.Sidecars, sidecar)
+			case gvk.AuthorizationPolicy:
+				spec, ok := c.Spec.(*security.AuthorizationPolicy)
+				if !ok {
+					return nil, fmt.Errorf("failed to convert spec in %q to AuthorizationPolicy", file)
+				}
+				policy := &securityv1.AuthorizationPolicy{ObjectMeta: withNamespace(c.ToObjectMeta())}
+				spec.DeepCopyInto(&policy.Spec)
+				out.AuthorizationPolicies = append(out.AuthorizationPolicies, policy)
+			case gvk.RequestAuthentication:
+				spec, ok := c.Spec.(*security.RequestAuthentication)
+				if !ok {
+					return nil, fmt.Errorf("failed to convert spec in %q to RequestAuthentication", file)
+				}
+				authentication := &securityv1.RequestAuthentication{ObjectMeta: withNamespace(c.ToObjectMeta())}
+				spec.DeepCopyInto(&authentication.Spec)
+				out.RequestAuthentications = append(out.RequestAuthentications, authentication)
+			case gvk.HTTPRoute:
+				spec, ok := c.Spec.(*gatewayv1.HTTPRouteSpec)
+				if !ok {
+					return nil, fmt.Errorf("failed to convert spec in %q to HTTPRoute", file)
+				}
+				out.HTTPRoutes = append(out.HTTPRoutes, &gatewayv1.HTTPRoute{
+					ObjectMeta: withNamespace(c.ToObjectMeta()),
+					Spec:       *spec,
+				})
+			case gvk.KubernetesGateway:
+				spec, ok := c.Spec.(*gatewayv1.GatewaySpec)
+				if !ok {
+					return nil, fmt.Errorf("failed to convert spec in %q to Gateway API Gateway", file)
+				}
+				out.KubernetesGateways = append(out.KubernetesGateways, &gatewayv1.Gateway{
+					ObjectMeta: withNamespace(c.ToObjectMeta()),
+					Spec:       *spec,
+				})
+			case gvk.ServiceEntry:
+				spec, ok := c.Spec.(*networking.ServiceEntry)
+				if !ok {
+					return nil, fmt.Errorf("failed to convert spec in %q to ServiceEntry", file)
+				}
+				entry := &v1.ServiceEntry{ObjectMeta: withNamespace(c.ToObjectMeta())}
+				spec.DeepCopyInto(&entry.Spec)
+				out.ServiceEntries = append(out.ServiceEntries, entry)
+			}
+		}
+		// Kubernetes resources are not istio configs and are left as they were read.
+		for _, other := range others {
+			switch {
+			case other.APIVersion == "v1" && other.Kind == "Service":
+				service := &corev1.Service{TypeMeta: other.TypeMeta, ObjectMeta: withNamespace(other.ObjectMeta)}
+				if err := convertSpec(other.Spec, &service.Spec); err != nil {
+					return nil, fmt.Errorf("failed to parse service %q in %q: %w", other.Name, file, err)
+				}
+				out.Services = append(out.Services, service)
+			case other.APIVersion == "networking.k8s.io/v1" && other.Kind == "Ingress":
+				ingress := &knetworking.Ingress{TypeMeta: other.TypeMeta, ObjectMeta: withNamespace(other.ObjectMeta)}
+				if err := convertSpec(other.Spec, &ingress.Spec); err != nil {
+					return nil, fmt.Errorf("failed to parse ingress %q in %q: %w", other.Name, file, err)
+				}
+				out.Ingresses = append(out.Ingresses, ingress)
+			}
+		}
+	}
+	return out, nil
+}
+
+// withNamespace returns the metadata of a resource, in DefaultNamespace unless it has a namespace.
+func withNamespace(meta metav1.ObjectMeta) metav1.ObjectMeta {
+	meta.Namespace = cmp.Or(meta.Namespace, DefaultNamespace)
+	return meta
+}
+
+// convertSpec converts the spec of a kubernetes resource, as read, into its typed spec.
+func convertSpec(spec any, out any) error {
+	raw, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// FQDN expands a short service name, e.g. "reviews", to the fully qualified name of the service in the given
+// namespace. Names with a dot are returned as they are.
+func FQDN(host, namespace string) string {
+	if strings.Contains(host, ".") || strings.HasPrefix(host, "*") || namespace == "" {
+		return host
+	}
+	return host + "." + namespace + ".svc.cluster.local"
+}
+
+// DestinationRule returns the destinationrule applying to the host of a destination declared in the given
+// namespace, or nil when there is none. As in istio, a rule for the exact host wins over the wildcard ones, and
+// the most specific wildcard wins over the others.
+func (c *Config) DestinationRule(host, namespace string) *v1.DestinationRule {
+	host = FQDN(host, namespace)
+	var out *v1.DestinationRule
+	var outHost string
+	for _, dr := range c.DestinationRules {
+		drHost := FQDN(dr.Spec.Host, dr.Namespace)
+		if drHost == host {
+			return dr
+		}
+		suffix, ok := strings.CutPrefix(drHost, "*")
+		if !ok || !strings.HasSuffix(host, suffix) {
+			continue
+		}
+		if out == nil || len(drHost) > len(outHost) {
+			out, outHost = dr, drHost
+		}
+	}
+	return out
+}
+
+// ServicePorts returns the ports of the kubernetes service or service entry defining the host of a destination
+// declared in the given namespace. It returns false when no service defines the host.
+func (c *Config) ServicePorts(host, namespace string) ([]uint32, bool) {
+	host = FQDN(host, namespace)
+	for _, service := range c.Services {
+		if FQDN(service.Name, service.Namespace) != host {
+			continue
+		}
+		var ports []uint32
+		for _, port := range service.Spec.Ports {
+			ports = append(ports, uint32(port.Port))
+		}
+		return ports, true
+	}
+	for _, se := range c.ServiceEntries {
+		for _, seHost := range se.Spec.Hosts {
+			if !MatchHost(host, FQDN(seHost, se.Namespace)) {
+				continue
+			}
+			var ports []uint32
+			for _, port := range se.Spec.Ports {
+				ports = append(ports, port.Number)
+			}
+			return ports, true
+		}
+	}
+	return nil, false
+}
+
+// MatchHost returns true when the name matches the host, which may be a wildcard such as "*.example.com".
+func MatchHost(name, host string) bool {
+	if host == "*" {
+		return true
+	}
+	if suffix, ok := strings.CutPrefix(host, "*"); ok {
+		return strings.HasSuffix(name, suffix)
+	}
+	return name == host
+}
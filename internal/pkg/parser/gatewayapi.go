@@ -0,0 +1,458 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+const (
+	// GatewayNameLabel labels the pods of the deployments istio generates for Gateway API gateways.
+	GatewayNameLabel = "gateway.networking.k8s.io/gateway-name"
+	// RouteSemanticsAnnotation marks the virtualservices generated for HTTPRoutes, whose prefix matches are
+	// matched on path segments as Gateway API requires.
+	RouteSemanticsAnnotation = "internal.istio.io/route-semantics"
+	// PrefixReplacement prefixes the uri of redirects and rewrites replacing the prefix the route matched instead of
+	// the whole path.
+	PrefixReplacement = "%PREFIX()%"
+
+	gatewayAPIGroup = "gateway.networking.k8s.io"
+)
+
+// GatewayAPIGateways returns the gateways istio generates for the listeners of the Gateway API gateways, see
+// GatewayAPIGatewayName. They select the pods of the generated deployment and have a server for the listener,
+// allowing routes of any namespace as the allowed namespaces are checked when the HTTPRoutes are converted.
+func (c *Config) GatewayAPIGateways() []*v1.Gateway {
+	var out []*v1.Gateway
+	for _, gw := range c.KubernetesGateways {
+		for _, listener := range gw.Spec.Listeners {
+			server := &networking.Server{
+				Port: &networking.Port{
+					Number:   uint32(listener.Port),
+					Name:     string(listener.Name),
+					Protocol: string(listener.Protocol),
+				},
+				Hosts: []string{"*/" + listenerHostname(listener)},
+				Name:  string(listener.Name),
+			}
+			if tls := listener.TLS; tls != nil {
+				server.Tls = &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE}
+				if tls.Mode != nil && *tls.Mode == gatewayv1.TLSModePassthrough {
+					server.Tls.Mode = networking.ServerTLSSettings_PASSTHROUGH
+				}
+				if len(tls.CertificateRefs) > 0 {
+					server.Tls.CredentialName = string(tls.CertificateRefs[0].Name)
+				}
+			}
+			out = append(out, &v1.Gateway{
+				ObjectMeta: metav1.ObjectMeta{Name: GatewayAPIGatewayName(gw.Name, string(listener.Name)), Namespace: gw.Namespace},
+				Spec: networking.Gateway{
+					Selector: map[string]string{GatewayNameLabel: gw.Name},
+					Servers:  []*networking.Server{server},
+				},
+			})
+		}
+	}
+	return out
+}
+
+// GatewayAPIGatewayName returns the name of the gateway istio generates for a listener of a Gateway API gateway.
+func GatewayAPIGatewayName(gateway, listener string) string {
+	return gateway + "-istio-autogenerated-k8s-gateway-" + listener
+}
+
+// IsGatewayAPIGateway returns true when the gateway was generated for a listener of the Gateway API gateway
+// with the given name, in the same namespace.
+func IsGatewayAPIGateway(gw *v1.Gateway, name string) bool {
+	return gw.Spec.Selector[GatewayNameLabel] == name && strings.HasPrefix(gw.Name, GatewayAPIGatewayName(name, ""))
+}
+
+// GatewayAPIVirtualServices returns the virtualservices istio generates for the Gateway API HTTPRoutes: one per
+// listener of the parent gateways and hostname, bound to the gateway generated for the listener and holding a
+// route per match of the HTTPRoutes attached to it. Routes are ordered by the Gateway API precedence: exact paths
+// first, then the longest prefixes, then the matches with a method, with the most headers and with the most query
+// parameters; ties are broken by the oldest HTTPRoute, its namespace and name, and the order of its rules.
+func (c *Config) GatewayAPIVirtualServices() []*v1.VirtualService {
+	httpRoutes := slices.Clone(c.HTTPRoutes)
+	slices.SortStableFunc(httpRoutes, func(a, b *gatewayv1.HTTPRoute) int {
+		return cmp.Or(
+			a.CreationTimestamp.Compare(b.CreationTimestamp.Time),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+
+	type group struct {
+		vs     *v1.VirtualService
+		routes []gatewayRoute
+	}
+	groups := map[string]*group{}
+	var keys []string
+	addRoutes := func(gateway string, httpRoute *gatewayv1.HTTPRoute, hostnames []string, routes []gatewayRoute) {
+		for _, hostname := range hostnames {
+			key := gateway + "/" + hostname
+			g, ok := groups[key]
+			if !ok {
+				_, name, _ := strings.Cut(gateway, "/")
+				g = &group{vs: &v1.VirtualService{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        name + "-" + strings.ReplaceAll(hostname, "*", "wildcard"),
+						Namespace:   httpRoute.Namespace,
+						Annotations: map[string]string{RouteSemanticsAnnotation: "gateway"},
+					},
+					Spec: networking.VirtualService{
+						Hosts:    []string{hostname},
+						Gateways: []string{gateway},
+					},
+				}}
+				groups[key] = g
+				keys = append(keys, key)
+			}
+			g.routes = append(g.routes, routes...)
+		}
+	}
+	for _, httpRoute := range httpRoutes {
+		routes := convertHTTPRoute(httpRoute)
+		for _, parent := range httpRoute.Spec.ParentRefs {
+			if cmp.Or(string(ptrValue(parent.Group)), gatewayAPIGroup) != gatewayAPIGroup || cmp.Or(string(ptrValue(parent.Kind)), "Gateway") != "Gateway" {
+				continue
+			}
+			namespace := cmp.Or(string(ptrValue(parent.Namespace)), httpRoute.Namespace)
+			gw := c.kubernetesGateway(namespace, string(parent.Name))
+			if gw == nil {
+				// Without the gateway, the route is bound to it as a whole, with its own hostnames.
+				addRoutes(namespace+"/"+string(parent.Name), httpRoute, routeHostnames(httpRoute, "*"), routes)
+				continue
+			}
+			for _, listener := range gw.Spec.Listeners {
+				if parent.SectionName != nil && *parent.SectionName != listener.Name || !allowsRoutes(gw, listener, httpRoute.Namespace) {
+					continue
+				}
+				ref := gw.Namespace + "/" + GatewayAPIGatewayName(gw.Name, string(listener.Name))
+				addRoutes(ref, httpRoute, routeHostnames(httpRoute, listenerHostname(listener)), routes)
+			}
+		}
+	}
+
+	var out []*v1.VirtualService
+	for _, key := range keys {
+		g := groups[key]
+		slices.SortStableFunc(g.routes, compareGatewayRoutes)
+		for _, route := range g.routes {
+			g.vs.Spec.Http = append(g.vs.Spec.Http, route.HTTPRoute)
+		}
+		out = append(out, g.vs)
+	}
+	return out
+}
+
+// kubernetesGateway returns the Gateway API gateway namespace/name, or nil when it is not part of the config.
+func (c *Config) kubernetesGateway(namespace, name string) *gatewayv1.Gateway {
+	for _, gw := range c.KubernetesGateways {
+		if gw.Namespace == namespace && gw.Name == name {
+			return gw
+		}
+	}
+	return nil
+}
+
+// allowsRoutes returns true when the listener of the gateway allows the routes of the namespace. Namespaces
+// selected by labels are assumed to be allowed.
+func allowsRoutes(gw *gatewayv1.Gateway, listener gatewayv1.Listener, namespace string) bool {
+	from := gatewayv1.NamespacesFromSame
+	if listener.AllowedRoutes != nil && listener.AllowedRoutes.Namespaces != nil && listener.AllowedRoutes.Namespaces.From != nil {
+		from = *listener.AllowedRoutes.Namespaces.From
+	}
+	switch from {
+	case gatewayv1.NamespacesFromAll, gatewayv1.NamespacesFromSelector:
+		return true
+	case gatewayv1.NamespacesFromSame:
+		return namespace == gw.Namespace
+	}
+	return false
+}
+
+// routeHostnames returns the hostnames of the HTTPRoute accepted by the listener hostname, the most specific of
+// both when one is a wildcard matching the other. Routes without hostnames get the listener one.
+func routeHostnames(httpRoute *gatewayv1.HTTPRoute, listenerHostname string) []string {
+	if len(httpRoute.Spec.Hostnames) == 0 {
+		return []string{listenerHostname}
+	}
+	var out []string
+	for _, hostname := range httpRoute.Spec.Hostnames {
+		switch {
+		case MatchHost(string(hostname), listenerHostname):
+			out = append(out, string(hostname))
+		case MatchHost(listenerHostname, string(hostname)):
+			out = append(out, listenerHostname)
+		}
+	}
+	return out
+}
+
+func listenerHostname(listener gatewayv1.Listener) string {
+	if listener.Hostname == nil || *listener.Hostname == "" {
+		return "*"
+	}
+	return string(*listener.Hostname)
+}
+
+// gatewayRoute is a route generated for a match of an HTTPRoute, along with the match to order it by.
+type gatewayRoute struct {
+	*networking.HTTPRoute
+	match gatewayv1.HTTPRouteMatch
+}
+
+// compareGatewayRoutes orders the routes by the Gateway API precedence of their matches.
+func compareGatewayRoutes(a, b gatewayRoute) int {
+	rank := func(route gatewayRoute) int {
+		switch pathType, _ := pathMatch(route.match); pathType {
+		case gatewayv1.PathMatchExact:
+			return 2
+		case gatewayv1.PathMatchPathPrefix:
+			return 1
+		}
+		return 0
+	}
+	_, pathA := pathMatch(a.match)
+	_, pathB := pathMatch(b.match)
+	return cmp.Or(
+		cmp.Compare(rank(b), rank(a)),
+		cmp.Compare(len(pathB), len(pathA)),
+		compareBool(b.match.Method != nil, a.match.Method != nil),
+		cmp.Compare(len(b.match.Headers), len(a.match.Headers)),
+		cmp.Compare(len(b.match.QueryParams), len(a.match.QueryParams)),
+	)
+}
+
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	}
+	return -1
+}
+
+// pathMatch returns the type and value of the path match, defaulting to the "/" prefix.
+func pathMatch(match gatewayv1.HTTPRouteMatch) (gatewayv1.PathMatchType, string) {
+	pathType, path := gatewayv1.PathMatchPathPrefix, "/"
+	if match.Path != nil {
+		pathType = cmp.Or(ptrValue(match.Path.Type), pathType)
+		path = cmp.Or(ptrValue(match.Path.Value), path)
+	}
+	return pathType, path
+}
+
+// convertHTTPRoute returns a route per match of the rules of the HTTPRoute, rules without matches matching all
+// requests. Routes are named after the HTTPRoute, the rule and the match, as namespace.name.rule.match.
+func convertHTTPRoute(httpRoute *gatewayv1.HTTPRoute) []gatewayRoute {
+	var out []gatewayRoute
+	for i, rule := range httpRoute.Spec.Rules {
+		matches := rule.Matches
+		if len(matches) == 0 {
+			matches = []gatewayv1.HTTPRouteMatch{{}}
+		}
+		for j, match := range matches {
+			route := convertRule(rule, httpRoute.Namespace)
+			route.Name = fmt.Sprintf("%s.%s.%d.%d", httpRoute.Namespace, httpRoute.Name, i, j)
+			route.Match = []*networking.HTTPMatchRequest{convertMatch(match)}
+			out = append(out, gatewayRoute{HTTPRoute: route, match: match})
+		}
+	}
+	return out
+}
+
+// convertMatch returns the match request of a Gateway API match. Paths default to the "/" prefix, and header and
+// query parameter matches to exact ones.
+func convertMatch(match gatewayv1.HTTPRouteMatch) *networking.HTTPMatchRequest {
+	out := &networking.HTTPMatchRequest{}
+	pathType, path := pathMatch(match)
+	switch pathType {
+	case gatewayv1.PathMatchExact:
+		out.Uri = &networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: path}}
+	case gatewayv1.PathMatchRegularExpression:
+		out.Uri = &networking.StringMatch{MatchType: &networking.StringMatch_Regex{Regex: path}}
+	default:
+		if path != "/" {
+			path = strings.TrimSuffix(path, "/")
+		}
+		out.Uri = &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: path}}
+	}
+	for _, header := range match.Headers {
+		if out.Headers == nil {
+			out.Headers = map[string]*networking.StringMatch{}
+		}
+		out.Headers[strings.ToLower(string(header.Name))] = stringMatch(header.Value, header.Type != nil && *header.Type == gatewayv1.HeaderMatchRegularExpression)
+	}
+	for _, param := range match.QueryParams {
+		if out.QueryParams == nil {
+			out.QueryParams = map[string]*networking.StringMatch{}
+		}
+		out.QueryParams[string(param.Name)] = stringMatch(param.Value, param.Type != nil && *param.Type == gatewayv1.QueryParamMatchRegularExpression)
+	}
+	if match.Method != nil {
+		out.Method = stringMatch(string(*match.Method), false)
+	}
+	return out
+}
+
+func stringMatch(value string, regex bool) *networking.StringMatch {
+	if regex {
+		return &networking.StringMatch{MatchType: &networking.StringMatch_Regex{Regex: value}}
+	}
+	return &networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: value}}
+}
+
+// convertRule returns the route of a rule: its backends, weighted, its filters and its request timeout. Rules
+// without backends nor redirect, or whose backends all have a zero weight, answer with a 500 as in istio.
+func convertRule(rule gatewayv1.HTTPRouteRule, namespace string) *networking.HTTPRoute {
+	out := &networking.HTTPRoute{}
+	for _, backend := range rule.BackendRefs {
+		weight := int32(1)
+		if backend.Weight != nil {
+			weight = *backend.Weight
+		}
+		if weight == 0 {
+			continue
+		}
+		out.Route = append(out.Route, &networking.HTTPRouteDestination{
+			Destination: backendDestination(backend.BackendObjectReference, namespace),
+			Weight:      weight,
+		})
+	}
+	if len(out.Route) == 1 {
+		out.Route[0].Weight = 0
+	}
+	for _, filter := range rule.Filters {
+		switch filter.Type {
+		case gatewayv1.HTTPRouteFilterRequestRedirect:
+			out.Redirect = convertRedirect(filter.RequestRedirect)
+		case gatewayv1.HTTPRouteFilterURLRewrite:
+			out.Rewrite = convertRewrite(filter.URLRewrite)
+		case gatewayv1.HTTPRouteFilterRequestHeaderModifier:
+			if out.Headers == nil {
+				out.Headers = &networking.Headers{}
+			}
+			out.Headers.Request = convertHeaderModifier(filter.RequestHeaderModifier)
+		case gatewayv1.HTTPRouteFilterResponseHeaderModifier:
+			if out.Headers == nil {
+				out.Headers = &networking.Headers{}
+			}
+			out.Headers.Response = convertHeaderModifier(filter.ResponseHeaderModifier)
+		case gatewayv1.HTTPRouteFilterRequestMirror:
+			out.Mirrors = append(out.Mirrors, convertMirror(filter.RequestMirror, namespace))
+		}
+	}
+	if out.Redirect != nil {
+		out.Route = nil
+	} else if len(out.Route) == 0 {
+		out.DirectResponse = &networking.HTTPDirectResponse{Status: 500}
+	}
+	if rule.Timeouts != nil && rule.Timeouts.Request != nil {
+		if timeout, err := time.ParseDuration(string(*rule.Timeouts.Request)); err == nil && timeout > 0 {
+			out.Timeout = durationpb.New(timeout)
+		}
+	}
+	return out
+}
+
+// backendDestination returns the destination of a backend: the service of the namespace by default, or the
+// host of an istio "Hostname" backend.
+func backendDestination(backend gatewayv1.BackendObjectReference, namespace string) *networking.Destination {
+	out := &networking.Destination{Host: string(backend.Name)}
+	if cmp.Or(string(ptrValue(backend.Kind)), "Service") == "Service" {
+		out.Host = FQDN(string(backend.Name), cmp.Or(string(ptrValue(backend.Namespace)), namespace))
+	}
+	if backend.Port != nil {
+		out.Port = &networking.PortSelector{Number: uint32(*backend.Port)}
+	}
+	return out
+}
+
+// convertRedirect returns the redirect of a RequestRedirect filter. Gateway API redirects default to a 302, and
+// prefix replacements use the PrefixReplacement uri.
+func convertRedirect(filter *gatewayv1.HTTPRequestRedirectFilter) *networking.HTTPRedirect {
+	out := &networking.HTTPRedirect{
+		Scheme:       ptrValue(filter.Scheme),
+		Authority:    string(ptrValue(filter.Hostname)),
+		RedirectCode: 302,
+	}
+	if filter.StatusCode != nil {
+		out.RedirectCode = uint32(*filter.StatusCode)
+	}
+	if filter.Port != nil {
+		out.RedirectPort = &networking.HTTPRedirect_Port{Port: uint32(*filter.Port)}
+	}
+	if path := filter.Path; path != nil {
+		switch path.Type {
+		case gatewayv1.FullPathHTTPPathModifier:
+			out.Uri = ptrValue(path.ReplaceFullPath)
+		case gatewayv1.PrefixMatchHTTPPathModifier:
+			out.Uri = PrefixReplacement + ptrValue(path.ReplacePrefixMatch)
+		}
+	}
+	return out
+}
+
+// convertRewrite returns the rewrite of a URLRewrite filter. Full path replacements rewrite the whole uri with a
+// regex, and prefix replacements use the PrefixReplacement uri, joining the replacement and the rest of the path
+// with a single slash as Gateway API requires.
+func convertRewrite(filter *gatewayv1.HTTPURLRewriteFilter) *networking.HTTPRewrite {
+	out := &networking.HTTPRewrite{Authority: string(ptrValue(filter.Hostname))}
+	if path := filter.Path; path != nil {
+		switch path.Type {
+		case gatewayv1.FullPathHTTPPathModifier:
+			out.UriRegexRewrite = &networking.RegexRewrite{Match: "/.*", Rewrite: ptrValue(path.ReplaceFullPath)}
+		case gatewayv1.PrefixMatchHTTPPathModifier:
+			out.Uri = PrefixReplacement + ptrValue(path.ReplacePrefixMatch)
+		}
+	}
+	return out
+}
+
+func convertHeaderModifier(filter *gatewayv1.HTTPHeaderFilter) *networking.Headers_HeaderOperations {
+	out := &networking.Headers_HeaderOperations{Remove: filter.Remove}
+	for _, header := range filter.Set {
+		if out.Set == nil {
+			out.Set = map[string]string{}
+		}
+		out.Set[string(header.Name)] = header.Value
+	}
+	for _, header := range filter.Add {
+		if out.Add == nil {
+			out.Add = map[string]string{}
+		}
+		out.Add[string(header.Name)] = header.Value
+	}
+	return out
+}
+
+func convertMirror(filter *gatewayv1.HTTPRequestMirrorFilter, namespace string) *networking.HTTPMirrorPolicy {
+	out := &networking.HTTPMirrorPolicy{Destination: backendDestination(filter.BackendRef, namespace)}
+	switch {
+	case filter.Percent != nil:
+		out.Percentage = &networking.Percent{Value: float64(*filter.Percent)}
+	case filter.Fraction != nil:
+		denominator := cmp.Or(ptrValue(filter.Fraction.Denominator), 100)
+		out.Percentage = &networking.Percent{Value: float64(filter.Fraction.Numerator) * 100 / float64(denominator)}
+	}
+	return out
+}
+
+func ptrValue[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
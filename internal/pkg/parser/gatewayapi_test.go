@@ -0,0 +1,148 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	networking "istio.io/api/networking/v1"
+	gatewayv1 "sigs.k8s.io/gateway-api/apis/v1"
+)
+
+func TestGatewayAPIGateways(t *testing.T) {
+	config, err := ParseConfig([]string{"../../../examples/httproute.yml"})
+	require.NoError(t, err)
+	require.Len(t, config.KubernetesGateways, 1)
+	require.Len(t, config.HTTPRoutes, 3)
+
+	gateways := config.GatewayAPIGateways()
+	require.Len(t, gateways, 2)
+	https := gateways[1]
+	require.Equal(t, "travel-istio-autogenerated-k8s-gateway-https", https.Name)
+	require.Equal(t, map[string]string{GatewayNameLabel: "travel"}, https.Spec.Selector)
+	require.True(t, IsGatewayAPIGateway(https, "travel"))
+	require.True(t, proto.Equal(&networking.Server{
+		Port:  &networking.Port{Number: 443, Name: "https", Protocol: "HTTPS"},
+		Hosts: []string{"*/*.travel.example.com"},
+		Name:  "https",
+		Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE, CredentialName: "travel-example-com-cert"},
+	}, https.Spec.Servers[0]))
+}
+
+func TestGatewayAPIVirtualServices(t *testing.T) {
+	config, err := ParseConfig([]string{"../../../examples/httproute.yml"})
+	require.NoError(t, err)
+
+	virtualServices := config.GatewayAPIVirtualServices()
+	require.Len(t, virtualServices, 2)
+	redirect, www := virtualServices[0], virtualServices[1]
+	require.Equal(t, []string{"*.travel.example.com"}, redirect.Spec.Hosts)
+	require.Equal(t, []string{"ingress/travel-istio-autogenerated-k8s-gateway-http"}, redirect.Spec.Gateways)
+	require.Equal(t, []string{"www.travel.example.com"}, www.Spec.Hosts)
+	require.Equal(t, []string{"ingress/travel-istio-autogenerated-k8s-gateway-https"}, www.Spec.Gateways)
+	require.Equal(t, "gateway", www.Annotations[RouteSemanticsAnnotation])
+
+	// Routes of both HTTPRoutes are merged and ordered by precedence.
+	var names []string
+	for _, route := range www.Spec.Http {
+		names = append(names, route.Name)
+	}
+	require.Equal(t, []string{"tours.tours.2.0", "tours.tours.1.0", "tours.tours.0.0", "tours.tours.3.0", "web.frontend.0.0"}, names)
+}
+
+func TestGatewayAPIVirtualServicesAllowedRoutes(t *testing.T) {
+	config, err := ParseConfig([]string{"../../../examples/httproute.yml"})
+	require.NoError(t, err)
+	same := gatewayv1.NamespacesFromSame
+	for i := range config.KubernetesGateways[0].Spec.Listeners {
+		config.KubernetesGateways[0].Spec.Listeners[i].AllowedRoutes.Namespaces.From = &same
+	}
+
+	virtualServices := config.GatewayAPIVirtualServices()
+	require.Len(t, virtualServices, 1)
+	require.Equal(t, "ingress", virtualServices[0].Namespace)
+}
+
+func TestConvertRule(t *testing.T) {
+	weight := func(w int32) *int32 { return &w }
+	port := gatewayv1.PortNumber(8080)
+	timeout := gatewayv1.Duration("5s")
+	slash := "/"
+	tests := []struct {
+		name string
+		rule gatewayv1.HTTPRouteRule
+		want *networking.HTTPRoute
+	}{
+		{
+			name: "single backend",
+			rule: gatewayv1.HTTPRouteRule{BackendRefs: []gatewayv1.HTTPBackendRef{
+				{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "tours", Port: &port}, Weight: weight(5)}},
+			}},
+			want: &networking.HTTPRoute{Route: []*networking.HTTPRouteDestination{
+				{Destination: &networking.Destination{Host: "tours.tours.svc.cluster.local", Port: &networking.PortSelector{Number: 8080}}},
+			}},
+		},
+		{
+			name: "zero weight backends",
+			rule: gatewayv1.HTTPRouteRule{BackendRefs: []gatewayv1.HTTPBackendRef{
+				{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "tours"}, Weight: weight(0)}},
+			}},
+			want: &networking.HTTPRoute{DirectResponse: &networking.HTTPDirectResponse{Status: 500}},
+		},
+		{
+			name: "mirror and timeout",
+			rule: gatewayv1.HTTPRouteRule{
+				BackendRefs: []gatewayv1.HTTPBackendRef{
+					{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "tours"}}},
+					{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "tours-canary"}}},
+				},
+				Filters: []gatewayv1.HTTPRouteFilter{{
+					Type: gatewayv1.HTTPRouteFilterRequestMirror,
+					RequestMirror: &gatewayv1.HTTPRequestMirrorFilter{
+						BackendRef: gatewayv1.BackendObjectReference{Name: "tours-shadow"},
+						Fraction:   &gatewayv1.Fraction{Numerator: 1, Denominator: weight(4)},
+					},
+				}},
+				Timeouts: &gatewayv1.HTTPRouteTimeouts{Request: &timeout},
+			},
+			want: &networking.HTTPRoute{
+				Route: []*networking.HTTPRouteDestination{
+					{Destination: &networking.Destination{Host: "tours.tours.svc.cluster.local"}, Weight: 1},
+					{Destination: &networking.Destination{Host: "tours-canary.tours.svc.cluster.local"}, Weight: 1},
+				},
+				Mirrors: []*networking.HTTPMirrorPolicy{{
+					Destination: &networking.Destination{Host: "tours-shadow.tours.svc.cluster.local"},
+					Percentage:  &networking.Percent{Value: 25},
+				}},
+				Timeout: durationpb.New(5 * time.Second),
+			},
+		},
+		{
+			name: "prefix rewrite",
+			rule: gatewayv1.HTTPRouteRule{
+				BackendRefs: []gatewayv1.HTTPBackendRef{
+					{BackendRef: gatewayv1.BackendRef{BackendObjectReference: gatewayv1.BackendObjectReference{Name: "tours"}}},
+				},
+				Filters: []gatewayv1.HTTPRouteFilter{{
+					Type: gatewayv1.HTTPRouteFilterURLRewrite,
+					URLRewrite: &gatewayv1.HTTPURLRewriteFilter{Path: &gatewayv1.HTTPPathModifier{
+						Type:               gatewayv1.PrefixMatchHTTPPathModifier,
+						ReplacePrefixMatch: &slash,
+					}},
+				}},
+			},
+			want: &networking.HTTPRoute{
+				Route:   []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "tours.tours.svc.cluster.local"}}},
+				Rewrite: &networking.HTTPRewrite{Uri: PrefixReplacement + "/"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertRule(tt.rule, "tours")
+			require.True(t, proto.Equal(tt.want, got), "got %v, want %v", got, tt.want)
+		})
+	}
+}
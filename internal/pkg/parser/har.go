@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// harFile is the subset of the HTTP Archive (HAR) format describing the captured requests.
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method  string `json:"method"`
+				URL     string `json:"url"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// ParseHAR returns an Input for each request captured in a HAR file. The scheme, authority, port and uri are
+// read from the request URL, header names are lowercased and pseudo-headers are left out.
+func ParseHAR(file string) ([]Input, error) {
+	fileContent, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading file %q failed: %w", file, err)
+	}
+	var har harFile
+	if err := json.Unmarshal(fileContent, &har); err != nil {
+		return nil, fmt.Errorf("unmarshaling HAR file %q failed: %w", file, err)
+	}
+
+	out := []Input{}
+	for i, entry := range har.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url in entry %d of %q: %w", i, file, err)
+		}
+		uri, query := splitURI(u.RequestURI())
+		var headers map[string]string
+		for _, header := range entry.Request.Headers {
+			name := strings.ToLower(header.Name)
+			// The host header and HTTP/2 pseudo-headers are already described by the request URL and method.
+			if name == "host" || strings.HasPrefix(name, ":") {
+				continue
+			}
+			if headers == nil {
+				headers = map[string]string{}
+			}
+			if previous, ok := headers[name]; ok {
+				separator := ", "
+				if name == "cookie" {
+					separator = "; "
+				}
+				header.Value = previous + separator + header.Value
+			}
+			headers[name] = header.Value
+		}
+		input := Input{Scheme: u.Scheme, Authority: u.Host, Method: entry.Request.Method, URI: uri, Headers: headers, Query: query}
+		// Virtualservice hosts never include the port.
+		if host, port, err := net.SplitHostPort(u.Host); err == nil {
+			number, err := strconv.ParseUint(port, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid port in entry %d of %q: %w", i, file, err)
+			}
+			input.Authority = host
+			input.Port = uint32(number)
+		}
+		out = append(out, input)
+	}
+	return out, nil
+}
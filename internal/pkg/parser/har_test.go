@@ -0,0 +1,43 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseHAR(t *testing.T) {
+	inputs, err := ParseHAR("testdata/session.har")
+	require.NoError(t, err)
+	require.Equal(t, []Input{{
+		Scheme:    "https",
+		Authority: "www.example.com",
+		Method:    "GET",
+		URI:       "/users/42",
+		Headers: map[string]string{
+			"user-agent": "Mozilla/5.0",
+			"cookie":     "session=abc; ab=beta",
+		},
+		Query: map[string]string{"tab": "bookings"},
+	}, {
+		Scheme:    "http",
+		Authority: "www.example.com",
+		Port:      8080,
+		Method:    "POST",
+		URI:       "/",
+	}}, inputs)
+
+	_, err = ParseHAR("testdata/missing.har")
+	require.Error(t, err)
+}
+
+func TestParseTestCasesHAR(t *testing.T) {
+	testCases, err := ParseTestCases([]string{"../../../examples/virtualservice_har_test.yml"}, true)
+	require.NoError(t, err)
+	require.Len(t, testCases, 1)
+	require.Equal(t, "../../../examples/captures/users.har", testCases[0].Request.HAR)
+
+	inputs, err := testCases[0].Request.Unfold()
+	require.NoError(t, err)
+	require.Len(t, inputs, 3)
+}
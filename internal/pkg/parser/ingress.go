@@ -0,0 +1,220 @@
+package parser
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	knetworking "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// IngressClass is the class of the ingresses istio serves, set by ingressClassName or by the
+	// IngressClassAnnotation.
+	IngressClass = "istio"
+	// IngressClassAnnotation is the legacy annotation setting the class of an ingress.
+	IngressClassAnnotation = "kubernetes.io/ingress.class"
+	// IngressNamespace is the namespace of the gateways istio generates for ingresses.
+	IngressNamespace = "istio-system"
+
+	ingressSuffix = "istio-autogenerated-k8s-ingress"
+)
+
+// IngressGateways returns the gateways istio generates for the ingresses of its class, see IngressGatewayName.
+// They select the istio ingress gateway and have an http server for all hosts, plus an https server for the
+// hosts of each tls entry of the ingress.
+func (c *Config) IngressGateways() []*v1.Gateway {
+	var out []*v1.Gateway
+	for _, ingress := range c.Ingresses {
+		if !IsIstioIngress(ingress) {
+			continue
+		}
+		servers := []*networking.Server{{
+			Port:  &networking.Port{Number: 80, Name: "http-80-ingress-" + ingress.Name + "-" + ingress.Namespace, Protocol: "HTTP"},
+			Hosts: []string{"*"},
+		}}
+		for i, tls := range ingress.Spec.TLS {
+			hosts := tls.Hosts
+			if len(hosts) == 0 {
+				hosts = []string{"*"}
+			}
+			servers = append(servers, &networking.Server{
+				Port:  &networking.Port{Number: 443, Name: fmt.Sprintf("https-443-ingress-%s-%s-%d", ingress.Name, ingress.Namespace, i), Protocol: "HTTPS"},
+				Hosts: hosts,
+				Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE, CredentialName: tls.SecretName},
+			})
+		}
+		out = append(out, &v1.Gateway{
+			ObjectMeta: metav1.ObjectMeta{Name: IngressGatewayName(ingress.Namespace, ingress.Name), Namespace: IngressNamespace},
+			Spec: networking.Gateway{
+				Selector: map[string]string{"istio": "ingressgateway"},
+				Servers:  servers,
+			},
+		})
+	}
+	return out
+}
+
+// IngressGatewayName returns the name of the gateway istio generates for an ingress, in IngressNamespace.
+func IngressGatewayName(namespace, name string) string {
+	return name + "-" + ingressSuffix + "-" + namespace
+}
+
+// IsIstioIngress returns true when the ingress is of the istio class, which istio serves in its default strict
+// ingress controller mode.
+func IsIstioIngress(ingress *knetworking.Ingress) bool {
+	if ingress.Spec.IngressClassName != nil {
+		return *ingress.Spec.IngressClassName == IngressClass
+	}
+	return ingress.Annotations[IngressClassAnnotation] == IngressClass
+}
+
+// IngressVirtualServices returns the virtualservices istio generates for the ingresses of its class: one per
+// host, merging the rules of all the ingresses for the host and bound to their gateways. Routes are ordered as
+// in istio: exact paths first, then the longest paths. Rules without host are served for all hosts, and the
+// default backend of ingresses is ignored.
+func (c *Config) IngressVirtualServices() []*v1.VirtualService {
+	ingresses := slices.Clone(c.Ingresses)
+	slices.SortStableFunc(ingresses, func(a, b *knetworking.Ingress) int {
+		return cmp.Or(
+			a.CreationTimestamp.Compare(b.CreationTimestamp.Time),
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+
+	type group struct {
+		vs     *v1.VirtualService
+		routes []ingressRoute
+	}
+	groups := map[string]*group{}
+	var hosts []string
+	for _, ingress := range ingresses {
+		if !IsIstioIngress(ingress) {
+			continue
+		}
+		gateway := IngressNamespace + "/" + IngressGatewayName(ingress.Namespace, ingress.Name)
+		for i, rule := range ingress.Spec.Rules {
+			if rule.HTTP == nil {
+				continue
+			}
+			host := cmp.Or(rule.Host, "*")
+			g, ok := groups[host]
+			if !ok {
+				g = &group{vs: &v1.VirtualService{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      strings.ReplaceAll(strings.ReplaceAll(host, "*", "wildcard"), ".", "-") + "-" + ingress.Name + "-" + ingressSuffix,
+						Namespace: ingress.Namespace,
+					},
+					Spec: networking.VirtualService{Hosts: []string{host}},
+				}}
+				groups[host] = g
+				hosts = append(hosts, host)
+			}
+			if !slices.Contains(g.vs.Spec.Gateways, gateway) {
+				g.vs.Spec.Gateways = append(g.vs.Spec.Gateways, gateway)
+			}
+			for j, path := range rule.HTTP.Paths {
+				route := c.convertIngressPath(ingress.Namespace, path)
+				route.Name = fmt.Sprintf("%s.%s.%d.%d", ingress.Namespace, ingress.Name, i, j)
+				g.routes = append(g.routes, route)
+			}
+		}
+	}
+
+	var out []*v1.VirtualService
+	for _, host := range hosts {
+		g := groups[host]
+		slices.SortStableFunc(g.routes, func(a, b ingressRoute) int {
+			return cmp.Or(
+				compareBool(b.exact, a.exact),
+				cmp.Compare(len(b.path), len(a.path)),
+			)
+		})
+		for _, route := range g.routes {
+			g.vs.Spec.Http = append(g.vs.Spec.Http, route.HTTPRoute)
+		}
+		out = append(out, g.vs)
+	}
+	return out
+}
+
+// ingressRoute is a route generated for a path of an ingress, along with the path to order it by.
+type ingressRoute struct {
+	*networking.HTTPRoute
+	exact bool
+	path  string
+}
+
+// convertIngressPath returns the route of an ingress path. Prefix paths match whole path segments, and
+// implementation specific ones are prefixes when they end with "/*" or ".*", exact paths otherwise.
+func (c *Config) convertIngressPath(namespace string, path knetworking.HTTPIngressPath) ingressRoute {
+	value := cmp.Or(path.Path, "/")
+	out := ingressRoute{HTTPRoute: &networking.HTTPRoute{}, path: value}
+	pathType := knetworking.PathTypeImplementationSpecific
+	if path.PathType != nil {
+		pathType = *path.PathType
+	}
+	exact := func(value string) *networking.HTTPMatchRequest {
+		return &networking.HTTPMatchRequest{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: value}}}
+	}
+	prefix := func(value string) *networking.HTTPMatchRequest {
+		return &networking.HTTPMatchRequest{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: value}}}
+	}
+	switch pathType {
+	case knetworking.PathTypeExact:
+		out.exact = true
+		out.Match = []*networking.HTTPMatchRequest{exact(value)}
+	case knetworking.PathTypePrefix:
+		if value == "/" {
+			out.Match = []*networking.HTTPMatchRequest{prefix(value)}
+			break
+		}
+		value = strings.TrimSuffix(value, "/")
+		out.Match = []*networking.HTTPMatchRequest{exact(value), prefix(value + "/")}
+	default:
+		if trimmed, ok := strings.CutSuffix(value, ".*"); ok {
+			out.Match = []*networking.HTTPMatchRequest{prefix(trimmed)}
+		} else if trimmed, ok := strings.CutSuffix(value, "/*"); ok {
+			out.Match = []*networking.HTTPMatchRequest{prefix(trimmed)}
+		} else {
+			out.exact = true
+			out.Match = []*networking.HTTPMatchRequest{exact(value)}
+		}
+	}
+
+	if service := path.Backend.Service; service != nil {
+		destination := &networking.Destination{Host: FQDN(service.Name, namespace)}
+		if port := c.servicePort(namespace, service); port != 0 {
+			destination.Port = &networking.PortSelector{Number: port}
+		}
+		out.Route = []*networking.HTTPRouteDestination{{Destination: destination}}
+	} else {
+		// Resource backends are not supported by istio.
+		out.DirectResponse = &networking.HTTPDirectResponse{Status: 503}
+	}
+	return out
+}
+
+// servicePort returns the number of the port of an ingress backend, looking up named ports in the kubernetes
+// services of the config. It returns 0 when a named port is not found.
+func (c *Config) servicePort(namespace string, backend *knetworking.IngressServiceBackend) uint32 {
+	if backend.Port.Number != 0 {
+		return uint32(backend.Port.Number)
+	}
+	for _, service := range c.Services {
+		if FQDN(service.Name, service.Namespace) != FQDN(backend.Name, namespace) {
+			continue
+		}
+		for _, port := range service.Spec.Ports {
+			if port.Name == backend.Port.Name {
+				return uint32(port.Port)
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	networking "istio.io/api/networking/v1"
+	knetworking "k8s.io/api/networking/v1"
+)
+
+func TestIngressGateways(t *testing.T) {
+	config, err := ParseConfig([]string{"../../../examples/ingress.yml"})
+	require.NoError(t, err)
+	require.Len(t, config.Ingresses, 2)
+
+	gateways := config.IngressGateways()
+	require.Len(t, gateways, 1)
+	require.Equal(t, "shop-istio-autogenerated-k8s-ingress-legacy", gateways[0].Name)
+	require.Equal(t, IngressNamespace, gateways[0].Namespace)
+	require.Len(t, gateways[0].Spec.Servers, 2)
+	require.True(t, proto.Equal(&networking.Server{
+		Port:  &networking.Port{Number: 443, Name: "https-443-ingress-shop-legacy-0", Protocol: "HTTPS"},
+		Hosts: []string{"shop.example.org"},
+		Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE, CredentialName: "shop-example-org-cert"},
+	}, gateways[0].Spec.Servers[1]))
+}
+
+func TestIngressVirtualServices(t *testing.T) {
+	config, err := ParseConfig([]string{"../../../examples/ingress.yml"})
+	require.NoError(t, err)
+
+	virtualServices := config.IngressVirtualServices()
+	require.Len(t, virtualServices, 1)
+	vs := virtualServices[0]
+	require.Equal(t, []string{"shop.example.org"}, vs.Spec.Hosts)
+	require.Equal(t, []string{"istio-system/shop-istio-autogenerated-k8s-ingress-legacy"}, vs.Spec.Gateways)
+
+	// Exact paths come first, then the longest paths.
+	var names []string
+	for _, route := range vs.Spec.Http {
+		names = append(names, route.Name)
+	}
+	require.Equal(t, []string{"legacy.shop.0.2", "legacy.shop.0.1", "legacy.shop.0.3", "legacy.shop.0.0"}, names)
+	require.True(t, proto.Equal(&networking.Destination{
+		Host: "shop.legacy.svc.cluster.local",
+		Port: &networking.PortSelector{Number: 8000},
+	}, vs.Spec.Http[3].Route[0].Destination))
+}
+
+func TestConvertIngressPath(t *testing.T) {
+	exact := func(value string) *networking.HTTPMatchRequest {
+		return &networking.HTTPMatchRequest{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: value}}}
+	}
+	prefix := func(value string) *networking.HTTPMatchRequest {
+		return &networking.HTTPMatchRequest{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: value}}}
+	}
+	pathType := func(t knetworking.PathType) *knetworking.PathType { return &t }
+	tests := []struct {
+		name string
+		path knetworking.HTTPIngressPath
+		want []*networking.HTTPMatchRequest
+	}{
+		{
+			name: "prefix",
+			path: knetworking.HTTPIngressPath{Path: "/shop/", PathType: pathType(knetworking.PathTypePrefix)},
+			want: []*networking.HTTPMatchRequest{exact("/shop"), prefix("/shop/")},
+		},
+		{
+			name: "root prefix",
+			path: knetworking.HTTPIngressPath{Path: "/", PathType: pathType(knetworking.PathTypePrefix)},
+			want: []*networking.HTTPMatchRequest{prefix("/")},
+		},
+		{
+			name: "exact",
+			path: knetworking.HTTPIngressPath{Path: "/shop", PathType: pathType(knetworking.PathTypeExact)},
+			want: []*networking.HTTPMatchRequest{exact("/shop")},
+		},
+		{
+			name: "implementation specific wildcard",
+			path: knetworking.HTTPIngressPath{Path: "/shop.*", PathType: pathType(knetworking.PathTypeImplementationSpecific)},
+			want: []*networking.HTTPMatchRequest{prefix("/shop")},
+		},
+		{
+			name: "implementation specific without type",
+			path: knetworking.HTTPIngressPath{Path: "/shop"},
+			want: []*networking.HTTPMatchRequest{exact("/shop")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{}
+			got := config.convertIngressPath("legacy", tt.path)
+			require.Len(t, got.Match, len(tt.want))
+			for i := range tt.want {
+				require.True(t, proto.Equal(tt.want[i], got.Match[i]), "match %d: %v", i, got.Match[i])
+			}
+		})
+	}
+}
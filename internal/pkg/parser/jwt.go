@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// TestKey is the HS256 key signing the tokens built from the claims of test requests. Signatures are not
+// verified when matching requests, so tokens signed with any key are accepted.
+const TestKey = "istio-config-validator-test-key"
+
+// ErrJWTAndClaims indicates a request with both a token and claims to sign
+var ErrJWTAndClaims = errors.New("jwt and claims are exclusive")
+
+// SignClaims returns a JWT carrying the claims, signed with TestKey.
+func SignClaims(claims map[string]any) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("encoding claims failed: %w", err)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(TestKey))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+// DecodeClaims returns the claims of a JWT, without verifying its signature.
+func DecodeClaims(token string) (map[string]any, error) {
+	payload, err := decodePayload(token)
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("invalid jwt claims: %w", err)
+	}
+	return claims, nil
+}
+
+// requestToken returns the token of a request, given as is or built from claims.
+func requestToken(jwt string, claims map[string]any) (string, error) {
+	if jwt != "" && claims != nil {
+		return "", ErrJWTAndClaims
+	}
+	if claims != nil {
+		return SignClaims(claims)
+	}
+	return jwt, nil
+}
+
+// jwtPayload returns the decoded payload of a token, or the token itself when it cannot be decoded.
+func jwtPayload(token string) string {
+	payload, err := decodePayload(token)
+	if err != nil {
+		return token
+	}
+	return string(payload)
+}
+
+func decodePayload(token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid jwt: %d parts, want 3", len(parts))
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid jwt payload: %w", err)
+	}
+	return payload, nil
+}
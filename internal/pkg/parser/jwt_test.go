@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignClaims(t *testing.T) {
+	claims := map[string]any{"iss": "https://auth.example.com", "aud": []any{"api"}, "exp": float64(1700000000)}
+	token, err := SignClaims(claims)
+	require.NoError(t, err)
+
+	got, err := DecodeClaims(token)
+	require.NoError(t, err)
+	require.Equal(t, claims, got)
+
+	_, err = DecodeClaims("not-a-token")
+	require.Error(t, err)
+}
+
+func TestRequestToken(t *testing.T) {
+	token, err := requestToken("a.b.c", nil)
+	require.NoError(t, err)
+	require.Equal(t, "a.b.c", token)
+
+	_, err = requestToken("a.b.c", map[string]any{"sub": "ci"})
+	require.ErrorIs(t, err, ErrJWTAndClaims)
+}
@@ -6,11 +6,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	yamlV3 "go.yaml.in/yaml/v4"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/durationpb"
 	networkingv1alpha3 "istio.io/api/networking/v1alpha3"
 )
 
@@ -21,6 +27,29 @@ var (
 	ErrEmptyMethodList = errors.New("method list is empty")
 	// ErrEmptyURIList indicates an empty URI list
 	ErrEmptyURIList = errors.New("URI list is empty")
+	// ErrEmptyAuthority indicates an explicit request without Authority
+	ErrEmptyAuthority = errors.New("authority is empty")
+	// ErrEmptyMethod indicates an explicit request without Method
+	ErrEmptyMethod = errors.New("method is empty")
+	// ErrEmptyURI indicates an explicit request without URI
+	ErrEmptyURI = errors.New("URI is empty")
+	// ErrNoRequest indicates a test case with neither a request nor a list of requests
+	ErrNoRequest = errors.New("test case has no request")
+	// ErrEmptySNIList indicates a tls request with an empty SNI list
+	ErrEmptySNIList = errors.New("SNI list is empty")
+	// ErrEmptyPortList indicates a tcp request with an empty Port list
+	ErrEmptyPortList = errors.New("port list is empty")
+	// ErrEmptyHeaderVariants indicates a header or cookie with an empty list of values
+	ErrEmptyHeaderVariants = errors.New("header variant list is empty")
+	// ErrUnknownProtocol indicates a request with a protocol other than http, tls or tcp
+	ErrUnknownProtocol = errors.New("unknown protocol")
+)
+
+// Protocols of the crafted requests.
+const (
+	ProtocolHTTP = "http"
+	ProtocolTLS  = "tls"
+	ProtocolTCP  = "tcp"
 )
 
 // TestCaseYAML define the list of TestCase
@@ -32,6 +61,7 @@ type TestCaseYAML struct {
 type TestCase struct {
 	Description string                                     `yaml:"description"`
 	Request     *Request                                   `yaml:"request"`
+	Requests    []*ExplicitRequest                         `yaml:"requests"`
 	Route       []*networkingv1alpha3.HTTPRouteDestination `yaml:"route"`
 	Redirect    *networkingv1alpha3.HTTPRedirect           `yaml:"redirect"`
 	Rewrite     *networkingv1alpha3.HTTPRewrite            `yaml:"rewrite"`
@@ -39,14 +69,316 @@ type TestCase struct {
 	Headers     *networkingv1alpha3.Headers                `yaml:"headers"`
 	Delegate    *networkingv1alpha3.Delegate               `yaml:"delegate"`
 	WantMatch   bool                                       `yaml:"wantMatch"`
+
+	// PathNormalization is the mesh path normalization applied to the requests before matching, one of NONE,
+	// BASE, MERGE_SLASHES or DECODE_AND_MERGE_SLASHES. It defaults to BASE, as in Istio.
+	PathNormalization string `yaml:"pathNormalization"`
+	// Distribution asserts the share of the traffic each weighted destination gets.
+	Distribution *Distribution `yaml:"distribution"`
+
+	Mirror           *networkingv1alpha3.Destination        `yaml:"mirror"`
+	Mirrors          []*networkingv1alpha3.HTTPMirrorPolicy `yaml:"mirrors"`
+	MirrorPercentage *networkingv1alpha3.Percent            `yaml:"mirrorPercentage"`
+	Timeout          *Duration                              `yaml:"timeout"`
+	Retries          *HTTPRetry                             `yaml:"retries"`
+	CorsPolicy       *CorsPolicy                            `yaml:"corsPolicy"`
+	// CORS asserts how Envoy's CORS filter answers the request, according to the CORS policy of the route.
+	CORS *CORS `yaml:"cors"`
+	// DirectResponse asserts the route answers the request itself, with no destination.
+	DirectResponse *DirectResponse `yaml:"directResponse"`
+	// ExpectResponse asserts the outcome of the request from the client point of view.
+	ExpectResponse *ExpectResponse `yaml:"expectResponse"`
+	// FaultSimulation asserts the share of the traffic the fault injection of the route aborts and delays.
+	FaultSimulation *FaultSimulation `yaml:"faultSimulation"`
+	// TrafficPolicy asserts the traffic policy the destinationrules apply to the destination of the route.
+	TrafficPolicy *TrafficPolicy `yaml:"trafficPolicy"`
+
+	// Gateway sends the requests through a gateway, as namespace/name or name. The requests are then only
+	// routed by the virtualservices bound to it, and fail when no server of the gateway accepts them.
+	Gateway string `yaml:"gateway"`
+	// Source sends the requests from a workload of the mesh. The requests are then only routed by the
+	// virtualservices the egress hosts of its sidecar resource allow.
+	Source *Source `yaml:"source"`
+	// Authorization asserts whether the authorizationpolicies allow the requests.
+	Authorization *Authorization `yaml:"authorization"`
+	// FollowRedirects re-issues the requests to the location they are redirected to, until they land on a route
+	// which does not redirect. The other assertions apply to that route, and redirect loops fail the test.
+	FollowRedirects bool `yaml:"followRedirects"`
+	// Journey asserts the hops of the requests across the mesh: after the first route, the request is routed
+	// again by the virtualservices of its destination host, as an in-mesh call, until it reaches a destination
+	// no virtualservice reroutes.
+	Journey []*Hop `yaml:"journey"`
+}
+
+// Hop is a step of the journey of a request: the destination a proxy routes it to and the request the
+// destination receives, once rewritten. The subset, uri and headers are only compared when set, other headers
+// are not compared.
+type Hop struct {
+	Destination string            `yaml:"destination"`
+	Subset      string            `yaml:"subset"`
+	URI         string            `yaml:"uri"`
+	Headers     map[string]string `yaml:"headers"`
+}
+
+// Source is a workload sending requests, identified by its namespace and labels.
+type Source struct {
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels"`
+	// Principal is the mTLS identity of the workload, e.g. cluster.local/ns/shop/sa/checkout. It defaults to the
+	// one of the default service account of the namespace.
+	Principal string `yaml:"principal"`
+}
+
+// Workload is a workload receiving requests, identified by its namespace and labels.
+type Workload struct {
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
+// Authorization asserts the decision of the authorizationpolicies applying to the workload receiving the
+// requests. The policy and rule deciding are only compared when set.
+type Authorization struct {
+	Workload Workload `yaml:"workload"`
+	Allowed  bool     `yaml:"allowed"`
+	// Policy is the namespace/name of the policy deciding.
+	Policy string `yaml:"policy"`
+	// Rule is the index of the rule of the policy deciding.
+	Rule *int `yaml:"rule"`
+}
+
+// FaultSimulation asserts the effect of the fault injection of the matched route on simulated requests. Aborts
+// and delays are drawn independently, so a request may be both delayed and aborted. They are only compared
+// when set.
+type FaultSimulation struct {
+	Simulation
+	Abort *FaultAbortShare `yaml:"abort"`
+	Delay *FaultDelayShare `yaml:"delay"`
+}
+
+// FaultAbortShare is the expected share, in percent, of the requests aborted, and their status.
+type FaultAbortShare struct {
+	Status  int32   `yaml:"status"`
+	Percent float64 `yaml:"percent"`
+}
+
+// FaultDelayShare is the expected share, in percent, of the requests delayed, and their delay.
+type FaultDelayShare struct {
+	Duration *Duration `yaml:"duration"`
+	Percent  float64   `yaml:"percent"`
+}
+
+// ExpectResponse is the expected outcome of a request, whatever the rule producing it: 404 when no rule
+// matches, the status of a fault aborting all requests, a redirect, a direct response or a 200 from a service.
+// Fields are only compared when set.
+type ExpectResponse struct {
+	Status uint32 `yaml:"status"`
+	// Destination is the host of the service answering the request, one of the weighted destinations.
+	Destination string `yaml:"destination"`
+	// Location is the absolute URL the request is redirected to.
+	Location string `yaml:"location"`
+}
+
+// DirectResponse is the expected response of a route with a directResponse. The status and body are only
+// compared when set.
+type DirectResponse struct {
+	Status uint32       `yaml:"status"`
+	Body   *StringMatch `yaml:"body"`
+}
+
+// StringMatch decodes string matches with protojson, as encoding/json does not understand their match types.
+type StringMatch struct {
+	*networkingv1alpha3.StringMatch
+}
+
+// UnmarshalJSON decodes the string match with protojson.
+func (sm *StringMatch) UnmarshalJSON(data []byte) error {
+	sm.StringMatch = &networkingv1alpha3.StringMatch{}
+	return protojson.Unmarshal(data, sm.StringMatch)
+}
+
+// CORS is the expected answer of Envoy's CORS filter to a request carrying an origin header. Preflights are
+// OPTIONS requests with an access-control-request-method header, and possibly an access-control-request-headers
+// one.
+type CORS struct {
+	Allowed bool `yaml:"allowed"`
+	// ResponseHeaders lists access-control-allow-* headers the response should have, other headers are not
+	// compared.
+	ResponseHeaders map[string]string `yaml:"responseHeaders"`
+}
+
+// Duration decodes durations such as "1.5s" or "1m", as encoding/json does not understand them.
+type Duration struct {
+	*durationpb.Duration
+}
+
+// UnmarshalJSON decodes the duration with time.ParseDuration.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = durationpb.New(duration)
+	return nil
+}
+
+// HTTPRetry decodes retry policies with protojson, as encoding/json does not understand their durations.
+type HTTPRetry struct {
+	*networkingv1alpha3.HTTPRetry
+}
+
+// UnmarshalJSON decodes the retry policy with protojson.
+func (r *HTTPRetry) UnmarshalJSON(data []byte) error {
+	r.HTTPRetry = &networkingv1alpha3.HTTPRetry{}
+	return protojson.Unmarshal(data, r.HTTPRetry)
+}
+
+// CorsPolicy decodes CORS policies with protojson, as encoding/json does not understand their string matches
+// and durations.
+type CorsPolicy struct {
+	*networkingv1alpha3.CorsPolicy
+}
+
+// UnmarshalJSON decodes the CORS policy with protojson.
+func (c *CorsPolicy) UnmarshalJSON(data []byte) error {
+	c.CorsPolicy = &networkingv1alpha3.CorsPolicy{}
+	return protojson.Unmarshal(data, c.CorsPolicy)
+}
+
+// TrafficPolicy decodes traffic policies with protojson, as encoding/json does not understand their durations
+// and enums.
+type TrafficPolicy struct {
+	*networkingv1alpha3.TrafficPolicy
+}
+
+// UnmarshalJSON decodes the traffic policy with protojson.
+func (t *TrafficPolicy) UnmarshalJSON(data []byte) error {
+	t.TrafficPolicy = &networkingv1alpha3.TrafficPolicy{}
+	return protojson.Unmarshal(data, t.TrafficPolicy)
+}
+
+// Simulation configures the random draws used to simulate traffic. The same seed always gives the same draws.
+type Simulation struct {
+	// Samples is the number of synthetic requests sent for each input, 1000 by default.
+	Samples int    `yaml:"samples"`
+	Seed    uint64 `yaml:"seed"`
+	// Tolerance is the accepted difference, in percentage points, between observed and expected shares, 5
+	// unless set. Zero requires the exact shares.
+	Tolerance *float64 `yaml:"tolerance"`
+}
+
+// Distribution asserts the share of the simulated traffic sent to each destination of the matched route.
+// Destinations of the route which are not listed should get no traffic.
+type Distribution struct {
+	Simulation
+	Destinations []*DestinationShare `yaml:"destinations"`
+}
+
+// DestinationShare is the expected share, in percent, of the traffic sent to a destination.
+type DestinationShare struct {
+	Destination *networkingv1alpha3.Destination `yaml:"destination"`
+	Percent     float64                         `yaml:"percent"`
 }
 
 // Request define the crafted http request present in the test case file.
 type Request struct {
-	Authority []string          `yaml:"authority"`
-	Method    []string          `yaml:"method"`
-	URI       []string          `yaml:"uri"`
+	Authority []string `yaml:"authority"`
+	Method    []string `yaml:"method"`
+	URI       []string `yaml:"uri"`
+	// Headers lists the values each header takes in the crafted requests, see HeaderVariants.
+	Headers map[string]HeaderVariants `yaml:"headers"`
+	// Cookies lists the values each cookie takes, in the same way as Headers. They are serialised into the
+	// cookie header, after the cookies it may already have.
+	Cookies map[string]HeaderVariants `yaml:"cookies"`
+	// HAR is the path to a HAR file whose captured requests are added to the crafted ones. Relative paths
+	// are resolved from the directory of the test case file.
+	HAR string `yaml:"har"`
+	// Exclude skips the combinations of authority, method and uri matching any of the exclusions.
+	Exclude []*RequestExclusion `yaml:"exclude"`
+	// JWT is the bearer token of the crafted requests. Claims may be given instead, to be signed with TestKey.
+	JWT    string         `yaml:"jwt"`
+	Claims map[string]any `yaml:"claims"`
+
+	// Protocol of the crafted requests: http, the default, tls or tcp. Tls requests are matched against the tls
+	// routes by SNI, tcp requests against the tcp routes by port and address.
+	Protocol string `yaml:"protocol"`
+	// Scheme lists the schemes of http requests, http or https. It is used to select the gateway server.
+	Scheme []string `yaml:"scheme"`
+	// SNI lists the server names of tls requests.
+	SNI []string `yaml:"sni"`
+	// Port lists the destination ports of the requests. Http requests default to the port of their scheme.
+	Port []uint32 `yaml:"port"`
+	// DestinationIP lists the destination addresses of tls and tcp requests, matched against destinationSubnets.
+	DestinationIP []string `yaml:"destinationIP"`
+}
+
+// HeaderVariants lists the values a request header takes. In test case files it is either a single value or a
+// list of values, where null stands for the header being absent:
+//
+//	headers:
+//	  x-user-type: [qa, beta, internal, null]
+type HeaderVariants struct {
+	Values []string
+	Absent bool
+}
+
+// UnmarshalJSON decodes a single value, null or a list of both.
+func (h *HeaderVariants) UnmarshalJSON(data []byte) error {
+	var variants []*string
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &variants); err != nil {
+			return fmt.Errorf("invalid header values: %w", err)
+		}
+		if len(variants) == 0 {
+			return ErrEmptyHeaderVariants
+		}
+	} else {
+		var value *string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return fmt.Errorf("invalid header value: %w", err)
+		}
+		variants = append(variants, value)
+	}
+	*h = HeaderVariants{}
+	for _, value := range variants {
+		if value == nil {
+			h.Absent = true
+			continue
+		}
+		h.Values = append(h.Values, *value)
+	}
+	return nil
+}
+
+// RequestExclusion matches the combinations of a Request to skip. Empty fields match any value.
+type RequestExclusion struct {
+	Authority string `yaml:"authority"`
+	Method    string `yaml:"method"`
+	URI       string `yaml:"uri"`
+}
+
+// ExplicitRequest define a single crafted http request, with its own headers and query parameters.
+type ExplicitRequest struct {
+	Authority string            `yaml:"authority"`
+	Method    string            `yaml:"method"`
+	URI       string            `yaml:"uri"`
 	Headers   map[string]string `yaml:"headers"`
+	Cookies   map[string]string `yaml:"cookies"`
+	Query     map[string]string `yaml:"query"`
+	// JWT and Claims give the bearer token of the request, see Request.
+	JWT    string         `yaml:"jwt"`
+	Claims map[string]any `yaml:"claims"`
+
+	// Protocol, SNI, Port and DestinationIP describe tls and tcp requests, see Request. Scheme and Port
+	// describe how http requests reach a gateway.
+	Protocol      string `yaml:"protocol"`
+	Scheme        string `yaml:"scheme"`
+	SNI           string `yaml:"sni"`
+	Port          uint32 `yaml:"port"`
+	DestinationIP string `yaml:"destinationIP"`
 }
 
 // Input contains the data structure which will be used to assert
@@ -55,6 +387,42 @@ type Input struct {
 	Method    string
 	URI       string
 	Headers   map[string]string
+	Query     map[string]string
+	// JWT is the bearer token of the request. Claims are the ones of the token, once a requestauthentication
+	// validated it, as available to claim based matches.
+	JWT    string
+	Claims map[string]any
+	// Gateway is the gateway routing the request, as namespace/name, empty for requests routed in the mesh.
+	Gateway string
+
+	// Protocol is empty for http requests, or one of ProtocolTLS and ProtocolTCP.
+	Protocol      string
+	Scheme        string
+	SNI           string
+	Port          uint32
+	DestinationIP string
+}
+
+// String describes the input. Http requests are described by their authority, method, uri, headers and query,
+// followed by their scheme, port and token claims when set; tls and tcp ones by the fields they are matched on.
+func (i Input) String() string {
+	switch i.Protocol {
+	case ProtocolTLS:
+		return fmt.Sprintf("{tls sni:%s port:%d ip:%s}", i.SNI, i.Port, i.DestinationIP)
+	case ProtocolTCP:
+		return fmt.Sprintf("{tcp port:%d ip:%s}", i.Port, i.DestinationIP)
+	}
+	var listener string
+	if i.Scheme != "" {
+		listener += " scheme:" + i.Scheme
+	}
+	if i.Port != 0 {
+		listener += fmt.Sprintf(" port:%d", i.Port)
+	}
+	if i.JWT != "" {
+		listener += " jwt:" + jwtPayload(i.JWT)
+	}
+	return fmt.Sprintf("{%s %s %s %v %v%s}", i.Authority, i.Method, i.URI, i.Headers, i.Query, listener)
 }
 
 // Destination define the destination we should assert
@@ -77,9 +445,35 @@ type Port struct {
 //		{Authority:"example.com", Method: "GET"},
 //		{Authority:"example.com", Method: "OPTIONS"},
 //	}
+//
+// Headers with several variants multiply the combinations, one per value and one without the header when it
+// may be absent.
+//
+// Tls and tcp requests combine their SNI, port and destination address instead.
+//
+// When a HAR file is given, its captured requests come first and the lists may be left empty.
 func (r *Request) Unfold() ([]Input, error) {
 	out := []Input{}
 
+	if r.HAR != "" {
+		captured, err := ParseHAR(r.HAR)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, captured...)
+		if len(r.Authority) == 0 && len(r.Method) == 0 && len(r.URI) == 0 {
+			return out, nil
+		}
+	}
+
+	switch r.Protocol {
+	case "", ProtocolHTTP:
+	case ProtocolTLS, ProtocolTCP:
+		return r.unfoldL4()
+	default:
+		return out, fmt.Errorf("%w: %q", ErrUnknownProtocol, r.Protocol)
+	}
+
 	if len(r.Authority) == 0 {
 		return out, ErrEmptyAuthorityList
 	}
@@ -90,15 +484,42 @@ func (r *Request) Unfold() ([]Input, error) {
 		return out, ErrEmptyURIList
 	}
 
-	for _, uri := range r.URI {
-		u, err := url.Parse(uri)
-		if err != nil {
-			return out, err
+	for _, variants := range []map[string]HeaderVariants{r.Headers, r.Cookies} {
+		for _, v := range variants {
+			if len(v.Values) == 0 && !v.Absent {
+				return out, ErrEmptyHeaderVariants
+			}
 		}
+	}
+
+	token, err := requestToken(r.JWT, r.Claims)
+	if err != nil {
+		return out, err
+	}
+	headers := r.headerCombinations()
+	schemes := r.Scheme
+	if len(schemes) == 0 {
+		schemes = []string{""}
+	}
+	ports := r.Port
+	if len(ports) == 0 {
+		ports = []uint32{0}
+	}
+	for _, uri := range r.URI {
+		path, query := splitURI(uri)
 
 		for _, auth := range r.Authority {
 			for _, method := range r.Method {
-				out = append(out, Input{Authority: auth, Method: method, URI: u.Path, Headers: r.Headers})
+				if r.excluded(auth, method, uri) {
+					continue
+				}
+				for _, h := range headers {
+					for _, scheme := range schemes {
+						for _, port := range ports {
+							out = append(out, Input{Authority: auth, Method: method, URI: path, Headers: h, Query: query, Scheme: scheme, Port: port, JWT: token})
+						}
+					}
+				}
 			}
 		}
 	}
@@ -106,6 +527,197 @@ func (r *Request) Unfold() ([]Input, error) {
 	return out, nil
 }
 
+// unfoldL4 returns the tls or tcp inputs for all the combinations of SNI, port and destination address. Tls
+// requests need a SNI, tcp ones a port.
+func (r *Request) unfoldL4() ([]Input, error) {
+	out := []Input{}
+	sniList := r.SNI
+	switch {
+	case r.Protocol == ProtocolTLS && len(r.SNI) == 0:
+		return out, ErrEmptySNIList
+	case r.Protocol == ProtocolTCP && len(r.Port) == 0:
+		return out, ErrEmptyPortList
+	case r.Protocol == ProtocolTCP:
+		sniList = []string{""}
+	}
+	ports := r.Port
+	if len(ports) == 0 {
+		ports = []uint32{0}
+	}
+	addresses := r.DestinationIP
+	if len(addresses) == 0 {
+		addresses = []string{""}
+	}
+	for _, sni := range sniList {
+		for _, port := range ports {
+			for _, address := range addresses {
+				out = append(out, Input{Protocol: r.Protocol, SNI: sni, Port: port, DestinationIP: address})
+			}
+		}
+	}
+	return out, nil
+}
+
+// headerCombinations returns every combination of the header and cookie variants. A request without headers
+// nor cookies has a single, nil, combination.
+func (r *Request) headerCombinations() []map[string]string {
+	var out []map[string]string
+	for _, headers := range combineVariants(r.Headers) {
+		for _, cookies := range combineVariants(r.Cookies) {
+			out = append(out, addCookies(headers, cookies))
+		}
+	}
+	return out
+}
+
+// combineVariants returns every combination of the given variants, by name.
+func combineVariants(variantsByName map[string]HeaderVariants) []map[string]string {
+	combinations := []map[string]string{nil}
+	for _, name := range slices.Sorted(maps.Keys(variantsByName)) {
+		variants := variantsByName[name]
+		var next []map[string]string
+		for _, combination := range combinations {
+			if variants.Absent {
+				next = append(next, combination)
+			}
+			for _, value := range variants.Values {
+				headers := maps.Clone(combination)
+				if headers == nil {
+					headers = map[string]string{}
+				}
+				headers[name] = value
+				next = append(next, headers)
+			}
+		}
+		combinations = next
+	}
+	return combinations
+}
+
+// addCookies returns a copy of the headers with the cookies, sorted by name, appended to the cookie header,
+// whatever the case of its name.
+func addCookies(headers, cookies map[string]string) map[string]string {
+	if len(cookies) == 0 {
+		return headers
+	}
+	key := "cookie"
+	for name := range headers {
+		if strings.EqualFold(name, "cookie") {
+			key = name
+		}
+	}
+	var pairs []string
+	if cookie := headers[key]; cookie != "" {
+		pairs = append(pairs, cookie)
+	}
+	for _, name := range slices.Sorted(maps.Keys(cookies)) {
+		pairs = append(pairs, name+"="+cookies[name])
+	}
+	out := maps.Clone(headers)
+	if out == nil {
+		out = map[string]string{}
+	}
+	out[key] = strings.Join(pairs, "; ")
+	return out
+}
+
+// excluded returns true when the combination matches one of the request exclusions.
+func (r *Request) excluded(authority, method, uri string) bool {
+	for _, exclusion := range r.Exclude {
+		if exclusion.Authority != "" && exclusion.Authority != authority {
+			continue
+		}
+		if exclusion.Method != "" && exclusion.Method != method {
+			continue
+		}
+		if exclusion.URI != "" && exclusion.URI != uri {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Input returns the Input described by the explicit request. Query parameters of the uri are merged with
+// the ones listed in Query, the latter taking precedence.
+func (r *ExplicitRequest) Input() (Input, error) {
+	switch r.Protocol {
+	case "", ProtocolHTTP:
+	case ProtocolTLS:
+		if r.SNI == "" {
+			return Input{}, ErrEmptySNIList
+		}
+		return Input{Protocol: r.Protocol, SNI: r.SNI, Port: r.Port, DestinationIP: r.DestinationIP}, nil
+	case ProtocolTCP:
+		if r.Port == 0 {
+			return Input{}, ErrEmptyPortList
+		}
+		return Input{Protocol: r.Protocol, Port: r.Port, DestinationIP: r.DestinationIP}, nil
+	default:
+		return Input{}, fmt.Errorf("%w: %q", ErrUnknownProtocol, r.Protocol)
+	}
+	if r.Authority == "" {
+		return Input{}, ErrEmptyAuthority
+	}
+	if r.Method == "" {
+		return Input{}, ErrEmptyMethod
+	}
+	if r.URI == "" {
+		return Input{}, ErrEmptyURI
+	}
+	token, err := requestToken(r.JWT, r.Claims)
+	if err != nil {
+		return Input{}, err
+	}
+	path, query := splitURI(r.URI)
+	for name, value := range r.Query {
+		if query == nil {
+			query = map[string]string{}
+		}
+		query[name] = value
+	}
+	return Input{Authority: r.Authority, Method: r.Method, URI: path, Headers: addCookies(r.Headers, r.Cookies), Query: query, Scheme: r.Scheme, Port: r.Port, JWT: token}, nil
+}
+
+// Inputs returns the inputs unfolded from the request followed by the ones of the explicit requests.
+func (tc *TestCase) Inputs() ([]Input, error) {
+	if tc.Request == nil && len(tc.Requests) == 0 {
+		return nil, ErrNoRequest
+	}
+	out := []Input{}
+	if tc.Request != nil {
+		inputs, err := tc.Request.Unfold()
+		if err != nil {
+			return out, err
+		}
+		out = append(out, inputs...)
+	}
+	for _, request := range tc.Requests {
+		input, err := request.Input()
+		if err != nil {
+			return out, err
+		}
+		out = append(out, input)
+	}
+	return out, nil
+}
+
+// splitURI splits a request uri into its path, kept as is for the path normalization to decode it, and its
+// query parameters. Only the first value of repeated query parameters is kept and invalid ones are skipped.
+func splitURI(uri string) (string, map[string]string) {
+	uri, _, _ = strings.Cut(uri, "#")
+	path, rawQuery, _ := strings.Cut(uri, "?")
+	values, _ := url.ParseQuery(rawQuery)
+	var query map[string]string
+	for name, v := range values {
+		if query == nil {
+			query = map[string]string{}
+		}
+		query[name] = v[0]
+	}
+	return path, query
+}
+
 func ParseTestCases(files []string, strict bool) ([]*TestCase, error) {
 	out := []*TestCase{}
 
@@ -139,6 +751,11 @@ func ParseTestCases(files []string, strict bool) ([]*TestCase, error) {
 				return nil, fmt.Errorf("unmarshaling failed for file %q: %w", file, err)
 			}
 
+			for _, testCase := range yamlFile.TestCases {
+				if testCase.Request != nil && testCase.Request.HAR != "" && !filepath.IsAbs(testCase.Request.HAR) {
+					testCase.Request.HAR = filepath.Join(filepath.Dir(file), testCase.Request.HAR)
+				}
+			}
 			out = append(out, yamlFile.TestCases...)
 		}
 	}
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -68,9 +70,9 @@ func TestUnfoldRequest(t *testing.T) {
 				Authority: []string{"www.example.com"},
 				Method:    []string{"GET"},
 				URI:       []string{"/"},
-				Headers: map[string]string{
-					"Cookie": "namnamnamnamnamnam",
-					"x-y-z":  "X-Y-Z",
+				Headers: map[string]HeaderVariants{
+					"Cookie": {Values: []string{"namnamnamnamnamnam"}},
+					"x-y-z":  {Values: []string{"X-Y-Z"}},
 				},
 			},
 			[]Input{
@@ -170,9 +172,9 @@ func TestUnfoldRequest(t *testing.T) {
 				Authority: []string{"www.example.com", "example.com"},
 				Method:    []string{"GET", "POST", "PUT"},
 				URI:       []string{"/", "/healthz"},
-				Headers: map[string]string{
-					"Cookie": "namnamnamnamnamnam",
-					"x-y-z":  "X-Y-Z",
+				Headers: map[string]HeaderVariants{
+					"Cookie": {Values: []string{"namnamnamnamnamnam"}},
+					"x-y-z":  {Values: []string{"X-Y-Z"}},
 				},
 			},
 			[]Input{
@@ -459,6 +461,17 @@ func TestUnfoldRequest(t *testing.T) {
 			[]Input{},
 			ErrEmptyAuthorityList,
 		},
+		{
+			"empty header variant list",
+			Request{
+				Authority: []string{"www.example.com"},
+				Method:    []string{"GET"},
+				URI:       []string{"/"},
+				Headers:   map[string]HeaderVariants{"x-user-type": {}},
+			},
+			[]Input{},
+			ErrEmptyHeaderVariants,
+		},
 		{
 			"empty method list and URI list",
 			Request{
@@ -469,6 +482,23 @@ func TestUnfoldRequest(t *testing.T) {
 			[]Input{},
 			ErrEmptyMethodList,
 		},
+		{
+			"percent-encoded paths are kept as sent",
+			Request{
+				Authority: []string{"www.example.com"},
+				Method:    []string{"GET"},
+				URI:       []string{"/caf%C3%A9/a%2Fb?q=a%20b"},
+			},
+			[]Input{
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/caf%C3%A9/a%2Fb",
+					Query:     map[string]string{"q": "a b"},
+				},
+			},
+			nil,
+		},
 		{
 			"query parameters should be removed",
 			Request{
@@ -481,6 +511,192 @@ func TestUnfoldRequest(t *testing.T) {
 					Authority: "www.example.com",
 					Method:    "POST",
 					URI:       "/reseller",
+					Query:     map[string]string{"partner_id": "12344"},
+				},
+			},
+			nil,
+		},
+		{
+			"header variants, including absence",
+			Request{
+				Authority: []string{"www.example.com"},
+				Method:    []string{"GET"},
+				URI:       []string{"/users"},
+				Headers: map[string]HeaderVariants{
+					"x-user-type": {Values: []string{"qa", "beta"}, Absent: true},
+					"x-y-z":       {Values: []string{"X-Y-Z"}},
+				},
+			},
+			[]Input{
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/users",
+					Headers:   map[string]string{"x-y-z": "X-Y-Z"},
+				},
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/users",
+					Headers:   map[string]string{"x-user-type": "qa", "x-y-z": "X-Y-Z"},
+				},
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/users",
+					Headers:   map[string]string{"x-user-type": "beta", "x-y-z": "X-Y-Z"},
+				},
+			},
+			nil,
+		},
+		{
+			"cookie variants are added to the cookie header",
+			Request{
+				Authority: []string{"www.example.com"},
+				Method:    []string{"GET"},
+				URI:       []string{"/"},
+				Headers: map[string]HeaderVariants{
+					"cookie": {Values: []string{"session=abc"}},
+				},
+				Cookies: map[string]HeaderVariants{
+					"variant": {Values: []string{"a", "b"}, Absent: true},
+					"lang":    {Values: []string{"en"}},
+				},
+			},
+			[]Input{
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/",
+					Headers:   map[string]string{"cookie": "session=abc; lang=en"},
+				},
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/",
+					Headers:   map[string]string{"cookie": "session=abc; lang=en; variant=a"},
+				},
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/",
+					Headers:   map[string]string{"cookie": "session=abc; lang=en; variant=b"},
+				},
+			},
+			nil,
+		},
+		{
+			"cookie variants are merged into the cookie header whatever its case",
+			Request{
+				Authority: []string{"www.example.com"},
+				Method:    []string{"GET"},
+				URI:       []string{"/"},
+				Headers: map[string]HeaderVariants{
+					"Cookie": {Values: []string{"session=abc"}},
+				},
+				Cookies: map[string]HeaderVariants{
+					"lang": {Values: []string{"en"}},
+				},
+			},
+			[]Input{
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/",
+					Headers:   map[string]string{"Cookie": "session=abc; lang=en"},
+				},
+			},
+			nil,
+		},
+		{
+			"tls requests",
+			Request{
+				Protocol:      ProtocolTLS,
+				SNI:           []string{"api.example.com", "www.example.com"},
+				Port:          []uint32{443},
+				DestinationIP: []string{"10.0.0.1"},
+			},
+			[]Input{
+				{Protocol: ProtocolTLS, SNI: "api.example.com", Port: 443, DestinationIP: "10.0.0.1"},
+				{Protocol: ProtocolTLS, SNI: "www.example.com", Port: 443, DestinationIP: "10.0.0.1"},
+			},
+			nil,
+		},
+		{
+			"schemes and ports",
+			Request{
+				Authority: []string{"api.example.com"},
+				Method:    []string{"GET"},
+				URI:       []string{"/"},
+				Scheme:    []string{"http", "https"},
+				Port:      []uint32{8443},
+			},
+			[]Input{
+				{Authority: "api.example.com", Method: "GET", URI: "/", Scheme: "http", Port: 8443},
+				{Authority: "api.example.com", Method: "GET", URI: "/", Scheme: "https", Port: 8443},
+			},
+			nil,
+		},
+		{
+			"tcp requests",
+			Request{
+				Protocol: ProtocolTCP,
+				Port:     []uint32{5432, 5433},
+			},
+			[]Input{
+				{Protocol: ProtocolTCP, Port: 5432},
+				{Protocol: ProtocolTCP, Port: 5433},
+			},
+			nil,
+		},
+		{
+			"empty SNI list",
+			Request{
+				Protocol: ProtocolTLS,
+				Port:     []uint32{443},
+			},
+			[]Input{},
+			ErrEmptySNIList,
+		},
+		{
+			"empty port list",
+			Request{
+				Protocol: ProtocolTCP,
+			},
+			[]Input{},
+			ErrEmptyPortList,
+		},
+		{
+			"excluded combinations are skipped",
+			Request{
+				Authority: []string{"www.example.com", "api.example.com"},
+				Method:    []string{"GET", "POST"},
+				URI:       []string{"/", "/api"},
+				Exclude: []*RequestExclusion{
+					{Authority: "api.example.com", URI: "/"},
+					{Authority: "www.example.com", Method: "POST"},
+				},
+			},
+			[]Input{
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/",
+				},
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/api",
+				},
+				{
+					Authority: "api.example.com",
+					Method:    "GET",
+					URI:       "/api",
+				},
+				{
+					Authority: "api.example.com",
+					Method:    "POST",
+					URI:       "/api",
 				},
 			},
 			nil,
@@ -497,3 +713,139 @@ func TestUnfoldRequest(t *testing.T) {
 		})
 	}
 }
+
+func TestInputString(t *testing.T) {
+	tests := []struct {
+		input Input
+		want  string
+	}{
+		{Input{Authority: "example.com", Method: "GET", URI: "/"}, "{example.com GET / map[] map[]}"},
+		{Input{Authority: "example.com", Method: "GET", URI: "/", Scheme: "https", Port: 8443}, "{example.com GET / map[] map[] scheme:https port:8443}"},
+		{Input{Protocol: ProtocolTLS, SNI: "api.example.com", Port: 443}, "{tls sni:api.example.com port:443 ip:}"},
+		{Input{Protocol: ProtocolTCP, Port: 5432, DestinationIP: "10.0.0.1"}, "{tcp port:5432 ip:10.0.0.1}"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, tt.input.String())
+	}
+}
+
+func TestHeaderVariantsUnmarshalJSON(t *testing.T) {
+	testCases := []struct {
+		Name string
+		In   string
+		Out  HeaderVariants
+	}{
+		{"single value", `"qa"`, HeaderVariants{Values: []string{"qa"}}},
+		{"null", `null`, HeaderVariants{Absent: true}},
+		{"list of values", `["qa", "beta"]`, HeaderVariants{Values: []string{"qa", "beta"}}},
+		{"list with absence", `["qa", null]`, HeaderVariants{Values: []string{"qa"}, Absent: true}},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			var got HeaderVariants
+			require.NoError(t, json.Unmarshal([]byte(testCase.In), &got))
+			require.Equal(t, testCase.Out, got)
+		})
+	}
+
+	var got HeaderVariants
+	require.ErrorIs(t, json.Unmarshal([]byte(`[]`), &got), ErrEmptyHeaderVariants)
+}
+
+func TestDurationUnmarshalJSON(t *testing.T) {
+	var got Duration
+	require.NoError(t, json.Unmarshal([]byte(`"1m30s"`), &got))
+	require.Equal(t, 90*time.Second, got.AsDuration())
+
+	require.Error(t, json.Unmarshal([]byte(`"90"`), &got))
+	require.Error(t, json.Unmarshal([]byte(`90`), &got))
+}
+
+func TestHTTPRetryUnmarshalJSON(t *testing.T) {
+	var got HTTPRetry
+	require.NoError(t, json.Unmarshal([]byte(`{"attempts": 3, "perTryTimeout": "2s"}`), &got))
+	require.EqualValues(t, 3, got.Attempts)
+	require.Equal(t, 2*time.Second, got.PerTryTimeout.AsDuration())
+}
+
+func TestTestCaseInputs(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		In    TestCase
+		Out   []Input
+		Error error
+	}{
+		{
+			"request and explicit requests",
+			TestCase{
+				Request: &Request{
+					Authority: []string{"www.example.com"},
+					Method:    []string{"GET"},
+					URI:       []string{"/"},
+				},
+				Requests: []*ExplicitRequest{{
+					Authority: "api.example.com",
+					Method:    "POST",
+					URI:       "/users?page=1",
+					Headers:   map[string]string{"x-user-type": "qa"},
+					Query:     map[string]string{"lang": "en"},
+				}, {
+					Authority: "api.example.com",
+					Method:    "DELETE",
+					URI:       "/users/1?page=2",
+					Cookies:   map[string]string{"variant": "b", "lang": "de"},
+					Query:     map[string]string{"page": "3"},
+				}},
+			},
+			[]Input{
+				{
+					Authority: "www.example.com",
+					Method:    "GET",
+					URI:       "/",
+				},
+				{
+					Authority: "api.example.com",
+					Method:    "POST",
+					URI:       "/users",
+					Headers:   map[string]string{"x-user-type": "qa"},
+					Query:     map[string]string{"page": "1", "lang": "en"},
+				},
+				{
+					Authority: "api.example.com",
+					Method:    "DELETE",
+					URI:       "/users/1",
+					Headers:   map[string]string{"cookie": "lang=de; variant=b"},
+					Query:     map[string]string{"page": "3"},
+				},
+			},
+			nil,
+		},
+		{
+			"explicit request without method",
+			TestCase{
+				Requests: []*ExplicitRequest{{
+					Authority: "api.example.com",
+					URI:       "/users",
+				}},
+			},
+			[]Input{},
+			ErrEmptyMethod,
+		},
+		{
+			"no request",
+			TestCase{},
+			nil,
+			ErrNoRequest,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			got, gotErr := testCase.In.Inputs()
+			if gotErr != testCase.Error {
+				t.Errorf("expected err=%v, got err=%v", testCase.Error, gotErr)
+			}
+			assert.Equal(t, testCase.Out, got)
+		})
+	}
+}
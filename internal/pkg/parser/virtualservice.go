@@ -1,40 +1,16 @@
 package parser
 
 import (
-	"fmt"
-	"os"
-
-	networking "istio.io/api/networking/v1"
 	v1 "istio.io/client-go/pkg/apis/networking/v1"
-	"istio.io/istio/pilot/pkg/config/kube/crd"
-	"istio.io/istio/pkg/config/schema/gvk"
 )
 
+// ParseVirtualServices parses the virtualservices of the given files, see ParseConfig, followed by the ones
+// generated for their Gateway API HTTPRoutes and their ingresses.
 func ParseVirtualServices(files []string) ([]*v1.VirtualService, error) {
-	out := []*v1.VirtualService{}
-	for _, file := range files {
-		fileContent, err := os.ReadFile(file)
-		if err != nil {
-			return nil, fmt.Errorf("reading file %q failed: %w", file, err)
-		}
-		configs, _, err := crd.ParseInputs(string(fileContent))
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse CRD %q: %w", file, err)
-		}
-		for _, c := range configs {
-			if c.GroupVersionKind != gvk.VirtualService {
-				continue
-			}
-			spec, ok := c.Spec.(*networking.VirtualService)
-			if !ok {
-				return nil, fmt.Errorf("failed to convert spec in %q to VirtualService: %w", file, err)
-			}
-			vs := &v1.VirtualService{
-				ObjectMeta: c.ToObjectMeta(),
-				Spec:       *spec, //nolint as deep copying mess up with reflect.DeepEqual comparison.
-			}
-			out = append(out, vs)
-		}
+	config, err := ParseConfig(files)
+	if err != nil {
+		return nil, err
 	}
-	return out, nil
+	out := append(config.VirtualServices, config.GatewayAPIVirtualServices()...)
+	return append(out, config.IngressVirtualServices()...), nil
 }
@@ -46,3 +46,48 @@ func TestVirtualServiceUnknownFields(t *testing.T) {
 	_, err := ParseVirtualServices(vsFiles)
 	require.ErrorContains(t, err, "cannot parse proto message")
 }
+
+func TestParseConfigDestinationRules(t *testing.T) {
+	config, err := ParseConfig([]string{"../../../examples/canary_virtualservice.yml"})
+	require.NoError(t, err)
+	require.Len(t, config.VirtualServices, 1)
+	require.Len(t, config.DestinationRules, 1)
+
+	dr := config.DestinationRule("search.search.svc.cluster.local", "example")
+	require.NotNil(t, dr)
+	require.Equal(t, "search", dr.Name)
+	require.Nil(t, config.DestinationRule("search", "example"))
+}
+
+func TestParseConfigServices(t *testing.T) {
+	config, err := ParseConfig([]string{"../../../examples/tls_services.yml"})
+	require.NoError(t, err)
+	require.Len(t, config.Services, 2)
+	require.Len(t, config.ServiceEntries, 1)
+
+	ports, ok := config.ServicePorts("payments-api", "payments")
+	require.True(t, ok)
+	require.Equal(t, []uint32{8443}, ports)
+	ports, ok = config.ServicePorts("postgres-replica.db.svc.cluster.local", "example")
+	require.True(t, ok)
+	require.Equal(t, []uint32{5432}, ports)
+	_, ok = config.ServicePorts("payments-api", "example")
+	require.False(t, ok)
+}
+
+func TestParseConfigDefaultNamespace(t *testing.T) {
+	config, err := ParseConfig([]string{"testdata/namespaceless.yml"})
+	require.NoError(t, err)
+	require.Len(t, config.VirtualServices, 1)
+	require.Equal(t, DefaultNamespace, config.VirtualServices[0].Namespace)
+	require.Equal(t, DefaultNamespace, config.DestinationRules[0].Namespace)
+	require.Equal(t, DefaultNamespace, config.Services[0].Namespace)
+
+	ports, ok := config.ServicePorts("reviews", config.VirtualServices[0].Namespace)
+	require.True(t, ok)
+	require.Equal(t, []uint32{9080}, ports)
+	require.NotNil(t, config.DestinationRule("reviews", config.VirtualServices[0].Namespace))
+	_, ok = config.ServicePorts("reviews.default.svc.cluster.local", "")
+	require.True(t, ok)
+	require.NotNil(t, config.DestinationRule("reviews.default.svc.cluster.local", ""))
+}
@@ -0,0 +1,138 @@
+package unit
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+)
+
+// corsResponse is how Envoy's CORS filter answers a request.
+type corsResponse struct {
+	Allowed         bool
+	ResponseHeaders map[string]string
+}
+
+// evaluateCORS replicates Envoy's CORS filter, configured by Istio from the route CORS policy. Preflights are
+// OPTIONS requests with origin and access-control-request-method headers; Envoy answers them when the origin
+// is allowed. A preflight is reported as allowed when, as a browser would check, the requested method and
+// headers are allowed as well. Other requests with an allowed origin get the allow-origin header added to the
+// upstream response.
+func evaluateCORS(input parser.Input, policy *networking.CorsPolicy) (corsResponse, error) {
+	response := corsResponse{ResponseHeaders: map[string]string{}}
+	origin := header(input, "origin")
+	if policy == nil || origin == "" {
+		return response, nil
+	}
+	allowed, err := originAllowed(origin, policy)
+	if err != nil || !allowed {
+		return response, err
+	}
+
+	response.ResponseHeaders["access-control-allow-origin"] = origin
+	if policy.GetAllowCredentials().GetValue() {
+		response.ResponseHeaders["access-control-allow-credentials"] = "true"
+	}
+
+	requestMethod := header(input, "access-control-request-method")
+	if input.Method != http.MethodOptions || requestMethod == "" {
+		if len(policy.ExposeHeaders) > 0 {
+			response.ResponseHeaders["access-control-expose-headers"] = strings.Join(policy.ExposeHeaders, ",")
+		}
+		response.Allowed = true
+		return response, nil
+	}
+
+	if len(policy.AllowMethods) > 0 {
+		response.ResponseHeaders["access-control-allow-methods"] = strings.Join(policy.AllowMethods, ",")
+	}
+	if len(policy.AllowHeaders) > 0 {
+		response.ResponseHeaders["access-control-allow-headers"] = strings.Join(policy.AllowHeaders, ",")
+	}
+	if policy.MaxAge != nil {
+		response.ResponseHeaders["access-control-max-age"] = strconv.FormatInt(int64(policy.MaxAge.AsDuration().Seconds()), 10)
+	}
+	response.Allowed = methodAllowed(requestMethod, policy.AllowMethods) &&
+		headersAllowed(header(input, "access-control-request-headers"), policy.AllowHeaders)
+	return response, nil
+}
+
+// originAllowed returns true when the origin matches one of the allowed origins. As in Envoy, a match
+// accepting "*" allows any origin.
+func originAllowed(origin string, policy *networking.CorsPolicy) (bool, error) {
+	if slices.Contains(policy.AllowOrigin, "*") || slices.Contains(policy.AllowOrigin, origin) {
+		return true, nil
+	}
+	for _, sm := range policy.AllowOrigins {
+		allowOrigin := &ExtendedStringMatch{sm}
+		for _, s := range []string{"*", origin} {
+			match, err := allowOrigin.Match(s)
+			if err != nil {
+				return false, err
+			}
+			if match {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// methodAllowed returns true for the methods browsers allow without listing them, and the listed ones.
+func methodAllowed(method string, allowMethods []string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+		return true
+	}
+	return slices.Contains(allowMethods, "*") || slices.Contains(allowMethods, method)
+}
+
+// headersAllowed returns true when all the requested headers, a comma separated list, are allowed.
+func headersAllowed(requestHeaders string, allowHeaders []string) bool {
+	if slices.Contains(allowHeaders, "*") {
+		return true
+	}
+	for _, name := range strings.Split(requestHeaders, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !slices.ContainsFunc(allowHeaders, func(allowed string) bool { return strings.EqualFold(allowed, name) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// header returns the value of a request header, whatever the case of its name.
+func header(input parser.Input, name string) string {
+	for key, value := range input.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// matchCORS returns whether Envoy's answer to the request matches the expected one. Only the listed response
+// headers are compared.
+func matchCORS(input parser.Input, route *networking.HTTPRoute, want *parser.CORS) (bool, corsResponse, error) {
+	response, err := evaluateCORS(input, route.CorsPolicy)
+	if err != nil {
+		return false, response, fmt.Errorf("evaluating cors policy failed: %w", err)
+	}
+	if response.Allowed != want.Allowed {
+		return false, response, nil
+	}
+	for name, value := range want.ResponseHeaders {
+		got, ok := response.ResponseHeaders[strings.ToLower(name)]
+		if !ok || got != value {
+			return false, response, nil
+		}
+	}
+	return true, response, nil
+}
@@ -0,0 +1,106 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	networking "istio.io/api/networking/v1"
+)
+
+func TestEvaluateCORS(t *testing.T) {
+	policy := &networking.CorsPolicy{
+		AllowOrigins: []*networking.StringMatch{
+			{MatchType: &networking.StringMatch_Exact{Exact: "https://www.example.com"}},
+			{MatchType: &networking.StringMatch_Regex{Regex: `https://.+\.example\.org`}},
+		},
+		AllowMethods:     []string{"GET", "PUT"},
+		AllowHeaders:     []string{"authorization", "content-type"},
+		ExposeHeaders:    []string{"x-request-id"},
+		MaxAge:           durationpb.New(86400e9),
+		AllowCredentials: wrapperspb.Bool(true),
+	}
+	preflight := func(origin, method, headers string) parser.Input {
+		return parser.Input{Authority: "www.example.com", Method: "OPTIONS", URI: "/", Headers: map[string]string{
+			"Origin":                         origin,
+			"Access-Control-Request-Method":  method,
+			"Access-Control-Request-Headers": headers,
+		}}
+	}
+	tests := []struct {
+		name   string
+		input  parser.Input
+		policy *networking.CorsPolicy
+		want   corsResponse
+	}{
+		{
+			name:   "allowed preflight",
+			input:  preflight("https://www.example.com", "PUT", "Content-Type"),
+			policy: policy,
+			want: corsResponse{Allowed: true, ResponseHeaders: map[string]string{
+				"access-control-allow-origin":      "https://www.example.com",
+				"access-control-allow-credentials": "true",
+				"access-control-allow-methods":     "GET,PUT",
+				"access-control-allow-headers":     "authorization,content-type",
+				"access-control-max-age":           "86400",
+			}},
+		},
+		{
+			name:   "preflight of a method not allowed",
+			input:  preflight("https://shop.example.org", "DELETE", ""),
+			policy: policy,
+			want: corsResponse{Allowed: false, ResponseHeaders: map[string]string{
+				"access-control-allow-origin":      "https://shop.example.org",
+				"access-control-allow-credentials": "true",
+				"access-control-allow-methods":     "GET,PUT",
+				"access-control-allow-headers":     "authorization,content-type",
+				"access-control-max-age":           "86400",
+			}},
+		},
+		{
+			name:   "preflight with a header not allowed",
+			input:  preflight("https://www.example.com", "GET", "x-debug"),
+			policy: &networking.CorsPolicy{AllowOrigin: []string{"*"}},
+			want: corsResponse{Allowed: false, ResponseHeaders: map[string]string{
+				"access-control-allow-origin": "https://www.example.com",
+			}},
+		},
+		{
+			name:   "preflight from an origin not allowed",
+			input:  preflight("https://evil.example.net", "GET", ""),
+			policy: policy,
+			want:   corsResponse{Allowed: false, ResponseHeaders: map[string]string{}},
+		},
+		{
+			name:   "simple request",
+			input:  parser.Input{Method: "GET", URI: "/", Headers: map[string]string{"origin": "https://www.example.com"}},
+			policy: policy,
+			want: corsResponse{Allowed: true, ResponseHeaders: map[string]string{
+				"access-control-allow-origin":      "https://www.example.com",
+				"access-control-allow-credentials": "true",
+				"access-control-expose-headers":    "x-request-id",
+			}},
+		},
+		{
+			name:   "no cors policy",
+			input:  preflight("https://www.example.com", "GET", ""),
+			policy: nil,
+			want:   corsResponse{Allowed: false, ResponseHeaders: map[string]string{}},
+		},
+		{
+			name:   "no origin",
+			input:  parser.Input{Method: "GET", URI: "/"},
+			policy: policy,
+			want:   corsResponse{Allowed: false, ResponseHeaders: map[string]string{}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := evaluateCORS(tt.input, tt.policy)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
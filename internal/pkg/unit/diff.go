@@ -0,0 +1,159 @@
+package unit
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"google.golang.org/protobuf/proto"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+)
+
+// Diff runs the same request corpus against two revisions of istio configuration and reports every
+// request whose route, rewrite, redirect, direct response or headers differ between them. The corpus is made
+// of all inputs declared in test cases plus the samples generated for each rule of both revisions, with their
+// paths normalized as in Run.
+func Diff(testfiles, baseConfigfiles, headConfigfiles []string, strict bool, opts ...optionFunc) ([]string, []string, error) {
+	var summary, details []string
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	testCases, err := parser.ParseTestCases(testfiles, strict)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing testcases failed: %w", err)
+	}
+	baseVirtualServices, err := parser.ParseVirtualServices(baseConfigfiles)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing base virtualservices failed: %w", err)
+	}
+	headVirtualServices, err := parser.ParseVirtualServices(headConfigfiles)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing head virtualservices failed: %w", err)
+	}
+
+	var corpus []parser.Input
+	for _, testCase := range testCases {
+		inputs, err := testCase.Inputs()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unfolding test %q failed: %w", testCase.Description, err)
+		}
+		pathNormalization := o.pathNormalization
+		if testCase.PathNormalization != "" {
+			if pathNormalization, err = ParsePathNormalization(testCase.PathNormalization); err != nil {
+				return nil, nil, fmt.Errorf("test %q: %w", testCase.Description, err)
+			}
+		}
+		corpus = append(corpus, normalizeInputs(inputs, pathNormalization)...)
+	}
+	corpus = append(corpus, normalizeInputs(SampleInputs(baseVirtualServices), o.pathNormalization)...)
+	corpus = append(corpus, normalizeInputs(SampleInputs(headVirtualServices), o.pathNormalization)...)
+	corpus = uniqueInputs(corpus)
+
+	diffCount := 0
+	for _, input := range corpus {
+		baseRoute, baseMissing, err := resolveRoute(input, baseVirtualServices)
+		if err != nil {
+			return summary, details, fmt.Errorf("error getting base route for input %v: %w", input, err)
+		}
+		headRoute, headMissing, err := resolveRoute(input, headVirtualServices)
+		if err != nil {
+			return summary, details, fmt.Errorf("error getting head route for input %v: %w", input, err)
+		}
+		changes := routeChanges(baseRoute, headRoute)
+		if baseMissing != "" && baseMissing != headMissing {
+			changes = append(changes, fmt.Sprintf("  delegate %s missing in base", baseMissing))
+		}
+		if headMissing != "" && headMissing != baseMissing {
+			changes = append(changes, fmt.Sprintf("  delegate %s missing in head", headMissing))
+		}
+		if len(changes) == 0 {
+			continue
+		}
+		diffCount++
+		details = append(details, fmt.Sprintf("DIFF input:[%v]", input))
+		details = append(details, changes...)
+	}
+
+	summary = append(summary, "Diff summary:")
+	summary = append(summary, fmt.Sprintf(" - %d testfiles, %d base configfiles, %d head configfiles", len(testfiles), len(baseConfigfiles), len(headConfigfiles)))
+	summary = append(summary, fmt.Sprintf(" - %d inputs compared, %d with differences", len(corpus), diffCount))
+	return summary, details, nil
+}
+
+// resolveRoute returns the route that matched a given input, following the delegate when the matched
+// route delegates to another virtualservice. When the delegate is missing from the revision, the delegating
+// route is returned along with the name of the missing delegate, so that the comparison goes on.
+func resolveRoute(input parser.Input, virtualServices []*v1.VirtualService) (*networking.HTTPRoute, string, error) {
+	checkHosts := true
+	route, err := GetRoute(input, virtualServices, checkHosts)
+	if err != nil || route.Delegate == nil {
+		return route, "", err
+	}
+	if _, err := GetDelegatedVirtualService(route.Delegate, virtualServices); err != nil {
+		return route, delegateName(route.Delegate), nil
+	}
+	route, err = resolveDelegate(input, route, virtualServices)
+	return route, "", err
+}
+
+// delegateName returns the name of the delegate, prefixed by its namespace when set.
+func delegateName(delegate *networking.Delegate) string {
+	if delegate.Namespace == "" {
+		return delegate.Name
+	}
+	return delegate.Namespace + "/" + delegate.Name
+}
+
+// routeChanges describes the differences between the routes of two revisions. Routes are compared with
+// proto.Equal, as printing a message changes its internal state and breaks reflect.DeepEqual.
+func routeChanges(base, head *networking.HTTPRoute) []string {
+	var changes []string
+	if !slices.EqualFunc(base.Route, head.Route, equalMessage) {
+		changes = append(changes, fmt.Sprintf("  route: %v -> %v", base.Route, head.Route))
+	}
+	if !proto.Equal(base.Rewrite, head.Rewrite) {
+		changes = append(changes, fmt.Sprintf("  rewrite: %v -> %v", base.Rewrite, head.Rewrite))
+	}
+	if !proto.Equal(base.Redirect, head.Redirect) {
+		changes = append(changes, fmt.Sprintf("  redirect: %v -> %v", base.Redirect, head.Redirect))
+	}
+	if !proto.Equal(base.DirectResponse, head.DirectResponse) {
+		changes = append(changes, fmt.Sprintf("  directResponse: %v -> %v", base.DirectResponse, head.DirectResponse))
+	}
+	if !proto.Equal(base.Headers, head.Headers) {
+		changes = append(changes, fmt.Sprintf("  headers: %v -> %v", base.Headers, head.Headers))
+	}
+	return changes
+}
+
+func equalMessage[M proto.Message](a, b M) bool {
+	return proto.Equal(a, b)
+}
+
+// normalizeInputs returns the inputs with their paths normalized.
+func normalizeInputs(inputs []parser.Input, normalization PathNormalization) []parser.Input {
+	out := make([]parser.Input, 0, len(inputs))
+	for _, input := range inputs {
+		input.URI = NormalizePath(input.URI, normalization)
+		out = append(out, input)
+	}
+	return out
+}
+
+// uniqueInputs drops the repeated inputs, keeping the first occurrence of each.
+func uniqueInputs(inputs []parser.Input) []parser.Input {
+	seen := map[string]bool{}
+	var out []parser.Input
+	for _, input := range inputs {
+		key := fmt.Sprintf("%v", input)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, input)
+	}
+	return out
+}
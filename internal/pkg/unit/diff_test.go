@@ -0,0 +1,93 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1"
+)
+
+func TestDiff(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_test.yml"}
+	basefiles := []string{"../../../examples/virtualservice.yml"}
+	headfiles := []string{"testdata/diff/virtualservice.yml"}
+	var strict bool
+
+	t.Run("no differences against itself", func(t *testing.T) {
+		summary, details, err := Diff(testcasefiles, basefiles, basefiles, strict)
+		require.NoError(t, err)
+		require.Empty(t, details)
+		require.Contains(t, summary[len(summary)-1], ", 0 with differences")
+	})
+
+	t.Run("report changed routes and headers", func(t *testing.T) {
+		_, details, err := Diff(testcasefiles, basefiles, headfiles, strict)
+		require.NoError(t, err)
+		output := strings.Join(details, "\n")
+		require.Contains(t, output, "DIFF input:[{www.example.com GET /users map[x-user-id:abc123] map[]}]")
+		require.Contains(t, output, "DIFF input:[{example.com GET /partners map[] map[]}]")
+		require.Contains(t, output, "partners-v2.partner.svc.cluster.local")
+		require.NotContains(t, output, "/reseller")
+	})
+
+	t.Run("normalize paths", func(t *testing.T) {
+		testcasefile := filepath.Join(t.TempDir(), "test.yml")
+		testcase := "testCases:\n  - description: dot segments\n    request: {authority: [example.com], method: [GET], uri: [/users/../partners]}\n"
+		require.NoError(t, os.WriteFile(testcasefile, []byte(testcase), 0o600))
+
+		_, details, err := Diff([]string{testcasefile}, basefiles, headfiles, strict)
+		require.NoError(t, err)
+		require.NotContains(t, strings.Join(details, "\n"), "/users/../partners")
+
+		_, details, err = Diff([]string{testcasefile}, basefiles, headfiles, strict, WithPathNormalization(meshconfig.MeshConfig_ProxyPathNormalization_NONE))
+		require.NoError(t, err)
+		require.Contains(t, strings.Join(details, "\n"), "DIFF input:[{example.com GET /users/../partners map[] map[]}]")
+	})
+
+	t.Run("report missing delegates", func(t *testing.T) {
+		testcasefiles := []string{"../../../examples/virtualservice_delegate_test.yml"}
+		basefiles := []string{"../../../examples/delegate_virtualservice.yml"}
+		headfiles := []string{"testdata/diff/delegate_virtualservice.yml"}
+
+		summary, details, err := Diff(testcasefiles, basefiles, basefiles, strict)
+		require.NoError(t, err)
+		require.Empty(t, details)
+		require.Contains(t, summary[len(summary)-1], ", 0 with differences")
+
+		_, details, err = Diff(testcasefiles, basefiles, headfiles, strict)
+		require.NoError(t, err)
+		output := strings.Join(details, "\n")
+		require.Contains(t, output, "delegate product-delegate missing in head")
+		require.NotContains(t, output, "seller-delegate")
+		require.NotContains(t, output, "/merchants")
+	})
+}
+
+func TestRouteChanges(t *testing.T) {
+	base := &networking.HTTPRoute{
+		Route: []*networking.HTTPRouteDestination{{
+			Destination: &networking.Destination{Host: "a.a.svc.cluster.local"},
+		}},
+	}
+	sameAsBase := &networking.HTTPRoute{
+		Route: []*networking.HTTPRouteDestination{{
+			Destination: &networking.Destination{Host: "a.a.svc.cluster.local"},
+		}},
+	}
+	rewritten := &networking.HTTPRoute{
+		Route: []*networking.HTTPRouteDestination{{
+			Destination: &networking.Destination{Host: "a.a.svc.cluster.local"},
+		}},
+		Rewrite: &networking.HTTPRewrite{Uri: "/"},
+	}
+
+	// printing a route must not make it differ from an identical one.
+	_ = base.String()
+	require.Empty(t, routeChanges(base, sameAsBase))
+	require.Len(t, routeChanges(base, rewritten), 1)
+	require.Len(t, routeChanges(base, &networking.HTTPRoute{}), 1)
+}
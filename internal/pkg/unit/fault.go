@@ -0,0 +1,77 @@
+package unit
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+)
+
+// faultOutcomes are the shares, in percent, of the simulated requests aborted and delayed by a fault injection.
+type faultOutcomes struct {
+	AbortStatus int32
+	Aborted     float64
+	Delay       time.Duration
+	Delayed     float64
+}
+
+func (o faultOutcomes) String() string {
+	return fmt.Sprintf("aborted with %d: %.1f%%, delayed by %v: %.1f%%", o.AbortStatus, o.Aborted, o.Delay, o.Delayed)
+}
+
+// simulateFaults draws, for each of the simulation samples, whether the fault delays and whether it aborts the
+// request. As in Envoy's fault filter, both are drawn independently, so a request may be delayed then aborted.
+func simulateFaults(fault *networking.HTTPFaultInjection, sim parser.Simulation) faultOutcomes {
+	outcomes := faultOutcomes{
+		AbortStatus: fault.GetAbort().GetHttpStatus(),
+		Delay:       fault.GetDelay().GetFixedDelay().AsDuration(),
+	}
+	abortPercent := fault.GetAbort().GetPercentage().GetValue()
+	// Istio falls back on the deprecated integer percent when the percentage is not set.
+	delayPercent := float64(fault.GetDelay().GetPercent())
+	if fault.GetDelay().GetPercentage() != nil {
+		delayPercent = fault.GetDelay().GetPercentage().GetValue()
+	}
+
+	samples := cmp.Or(sim.Samples, defaultSamples)
+	r := newRand(sim)
+	var aborted, delayed int
+	for range samples {
+		if fault.GetDelay() != nil && r.Float64()*100 < delayPercent {
+			delayed++
+		}
+		if fault.GetAbort() != nil && r.Float64()*100 < abortPercent {
+			aborted++
+		}
+	}
+	outcomes.Aborted = 100 * float64(aborted) / float64(samples)
+	outcomes.Delayed = 100 * float64(delayed) / float64(samples)
+	return outcomes
+}
+
+// matchFaultSimulation simulates the fault injection of the route and returns whether the shares of aborted and
+// delayed requests are within the tolerance of the expected ones, along with the simulated outcomes.
+func matchFaultSimulation(route *networking.HTTPRoute, want *parser.FaultSimulation) (bool, faultOutcomes) {
+	outcomes := simulateFaults(route.Fault, want.Simulation)
+	tolerance := simulationTolerance(want.Simulation)
+	if want.Abort != nil {
+		if want.Abort.Status != 0 && outcomes.AbortStatus != want.Abort.Status {
+			return false, outcomes
+		}
+		if math.Abs(outcomes.Aborted-want.Abort.Percent) > tolerance {
+			return false, outcomes
+		}
+	}
+	if want.Delay != nil {
+		if want.Delay.Duration != nil && outcomes.Delay != want.Delay.Duration.AsDuration() {
+			return false, outcomes
+		}
+		if math.Abs(outcomes.Delayed-want.Delay.Percent) > tolerance {
+			return false, outcomes
+		}
+	}
+	return true, outcomes
+}
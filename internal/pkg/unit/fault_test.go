@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+	networking "istio.io/api/networking/v1"
+)
+
+func TestSimulateFaults(t *testing.T) {
+	fault := &networking.HTTPFaultInjection{
+		Abort: &networking.HTTPFaultInjection_Abort{
+			ErrorType:  &networking.HTTPFaultInjection_Abort_HttpStatus{HttpStatus: 503},
+			Percentage: &networking.Percent{Value: 50},
+		},
+		Delay: &networking.HTTPFaultInjection_Delay{
+			HttpDelayType: &networking.HTTPFaultInjection_Delay_FixedDelay{FixedDelay: durationpb.New(2 * time.Second)},
+			Percentage:    &networking.Percent{Value: 10},
+		},
+	}
+	sim := parser.Simulation{Samples: 10000, Seed: 3}
+	got := simulateFaults(fault, sim)
+	require.Equal(t, got, simulateFaults(fault, sim), "the same seed should give the same outcomes")
+	require.EqualValues(t, 503, got.AbortStatus)
+	require.Equal(t, 2*time.Second, got.Delay)
+	require.InDelta(t, 50, got.Aborted, 2)
+	require.InDelta(t, 10, got.Delayed, 2)
+
+	require.Equal(t, faultOutcomes{}, simulateFaults(nil, sim))
+
+	deprecated := &networking.HTTPFaultInjection{Delay: &networking.HTTPFaultInjection_Delay{Percent: 100}}
+	require.InDelta(t, 100, simulateFaults(deprecated, sim).Delayed, 0)
+}
+
+func TestMatchFaultSimulation(t *testing.T) {
+	route := &networking.HTTPRoute{Fault: &networking.HTTPFaultInjection{
+		Abort: &networking.HTTPFaultInjection_Abort{
+			ErrorType:  &networking.HTTPFaultInjection_Abort_HttpStatus{HttpStatus: 503},
+			Percentage: &networking.Percent{Value: 50},
+		},
+	}}
+	tests := []struct {
+		name string
+		want *parser.FaultSimulation
+		ok   bool
+	}{
+		{
+			name: "within tolerance",
+			want: &parser.FaultSimulation{Abort: &parser.FaultAbortShare{Status: 503, Percent: 48}},
+			ok:   true,
+		},
+		{
+			name: "other status",
+			want: &parser.FaultSimulation{Abort: &parser.FaultAbortShare{Status: 500, Percent: 50}},
+			ok:   false,
+		},
+		{
+			name: "out of tolerance",
+			want: &parser.FaultSimulation{
+				Simulation: parser.Simulation{Tolerance: new(1.0)},
+				Abort:      &parser.FaultAbortShare{Percent: 40},
+			},
+			ok: false,
+		},
+		{
+			name: "zero tolerance",
+			want: &parser.FaultSimulation{
+				Simulation: parser.Simulation{Tolerance: new(0.0)},
+				Abort:      &parser.FaultAbortShare{Percent: 48},
+			},
+			ok: false,
+		},
+		{
+			name: "no delay",
+			want: &parser.FaultSimulation{Delay: &parser.FaultDelayShare{Percent: 0}},
+			ok:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := matchFaultSimulation(route, tt.want)
+			require.Equal(t, tt.ok, got)
+		})
+	}
+}
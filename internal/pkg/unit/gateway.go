@@ -0,0 +1,169 @@
+package unit
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+)
+
+// findGateways returns the gateway referenced as namespace/name, or by name alone. Gateway API gateways are
+// referenced by their own name too, which returns the gateways istio generates for their listeners, and
+// ingresses by their namespace/name, which returns the gateway istio generates for them.
+func findGateways(gateways []*v1.Gateway, ref string) ([]*v1.Gateway, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok {
+		namespace, name = "", ref
+	}
+	var out []*v1.Gateway
+	for _, gw := range gateways {
+		if gw.Namespace == parser.IngressNamespace && gw.Name == parser.IngressGatewayName(namespace, name) {
+			return []*v1.Gateway{gw}, nil
+		}
+		if namespace != "" && gw.Namespace != namespace {
+			continue
+		}
+		if gw.Name == name {
+			return []*v1.Gateway{gw}, nil
+		}
+		if parser.IsGatewayAPIGateway(gw, name) {
+			out = append(out, gw)
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("gateway %q not found", ref)
+	}
+	return out, nil
+}
+
+// listener returns the scheme and port the request is sent to. The scheme defaults to https for tls requests
+// and requests on port 443, and to http otherwise, the port to the one of the scheme.
+func listener(input parser.Input) (string, uint32) {
+	scheme := strings.ToLower(input.Scheme)
+	if scheme == "" {
+		scheme = "http"
+		if input.Port == 443 || input.Protocol == parser.ProtocolTLS {
+			scheme = "https"
+		}
+	}
+	port := input.Port
+	if port == 0 {
+		port = 80
+		if scheme == "https" {
+			port = 443
+		}
+	}
+	return scheme, port
+}
+
+// selectServer returns the server of the gateway accepting the request, or nil when none does. Servers are
+// selected by port, by protocol and by host: the authority of http requests, the SNI of tls ones. Https requests
+// need a server terminating tls, tls requests one passing it through.
+func selectServer(gw *v1.Gateway, input parser.Input) *networking.Server {
+	scheme, port := listener(input)
+	host := input.Authority
+	if input.Protocol == parser.ProtocolTLS {
+		host = input.SNI
+	}
+	host, _, _ = strings.Cut(host, ":")
+	for _, server := range gw.Spec.Servers {
+		if server.GetPort().GetNumber() != port {
+			continue
+		}
+		protocol := strings.ToUpper(server.GetPort().GetProtocol())
+		passthrough := server.GetTls().GetMode() == networking.ServerTLSSettings_PASSTHROUGH ||
+			server.GetTls().GetMode() == networking.ServerTLSSettings_AUTO_PASSTHROUGH
+		switch input.Protocol {
+		case parser.ProtocolTCP:
+			if protocol == "TCP" {
+				return server
+			}
+			continue
+		case parser.ProtocolTLS:
+			if (protocol != "TLS" && protocol != "HTTPS") || !passthrough {
+				continue
+			}
+		default:
+			switch {
+			case scheme == "https" && protocol == "HTTPS" && !passthrough:
+			case scheme == "http" && slices.Contains([]string{"HTTP", "HTTP2", "GRPC"}, protocol):
+			default:
+				continue
+			}
+		}
+		if slices.ContainsFunc(server.Hosts, func(serverHost string) bool {
+			_, serverHost = serverHostNamespace(serverHost)
+			return parser.MatchHost(host, serverHost)
+		}) {
+			return server
+		}
+	}
+	return nil
+}
+
+// selectGatewayServer returns the first of the gateways with a server accepting the request, and the server,
+// see selectServer.
+func selectGatewayServer(gateways []*v1.Gateway, input parser.Input) (*v1.Gateway, *networking.Server) {
+	for _, gw := range gateways {
+		if server := selectServer(gw, input); server != nil {
+			return gw, server
+		}
+	}
+	return nil, nil
+}
+
+// serverHostNamespace splits a server host, e.g. "prod/*.example.com", into the namespace of the virtualservices
+// allowed to bind it and the host itself. Hosts without namespace allow any.
+func serverHostNamespace(serverHost string) (string, string) {
+	namespace, host, ok := strings.Cut(serverHost, "/")
+	if !ok {
+		return "*", serverHost
+	}
+	return namespace, host
+}
+
+// boundVirtualServices returns the virtualservices bound to the gateway and allowed by the server hosts matching
+// the host of the request, the authority or the SNI. Gateway references of virtualservices without namespace are in their own namespace.
+func boundVirtualServices(virtualServices []*v1.VirtualService, gw *v1.Gateway, server *networking.Server, host string) []*v1.VirtualService {
+	host, _, _ = strings.Cut(host, ":")
+	var namespaces []string
+	if host == "" {
+		// Tcp requests are not matched by host.
+		namespaces = append(namespaces, "*")
+	}
+	for _, serverHost := range server.Hosts {
+		namespace, serverHost := serverHostNamespace(serverHost)
+		if namespace == "." {
+			namespace = gw.Namespace
+		}
+		if parser.MatchHost(host, serverHost) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	var out []*v1.VirtualService
+	for _, vs := range virtualServices {
+		if !slices.Contains(namespaces, "*") && !slices.Contains(namespaces, vs.Namespace) {
+			continue
+		}
+		bound := slices.ContainsFunc(vs.Spec.Gateways, func(ref string) bool {
+			namespace, name, ok := strings.Cut(ref, "/")
+			if !ok {
+				namespace, name = vs.Namespace, ref
+			}
+			return namespace == gw.Namespace && name == gw.Name
+		})
+		if bound {
+			out = append(out, vs)
+		}
+	}
+	return out
+}
+
+// httpsRedirectRoute is the route of requests answered by a server redirecting http to https.
+func httpsRedirectRoute() *networking.HTTPRoute {
+	return &networking.HTTPRoute{Redirect: &networking.HTTPRedirect{Scheme: "https", RedirectCode: http.StatusMovedPermanently}}
+}
@@ -0,0 +1,88 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSelectServer(t *testing.T) {
+	gw := &v1.Gateway{
+		ObjectMeta: metav1.ObjectMeta{Name: "public", Namespace: "istio-system"},
+		Spec: networking.Gateway{Servers: []*networking.Server{
+			{
+				Name:  "http",
+				Port:  &networking.Port{Number: 80, Protocol: "HTTP"},
+				Hosts: []string{"*/api.example.com"},
+			},
+			{
+				Name:  "https",
+				Port:  &networking.Port{Number: 443, Protocol: "HTTPS"},
+				Hosts: []string{"api.example.com"},
+				Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_SIMPLE},
+			},
+			{
+				Name:  "passthrough",
+				Port:  &networking.Port{Number: 443, Protocol: "TLS"},
+				Hosts: []string{"*.payments.example.com"},
+				Tls:   &networking.ServerTLSSettings{Mode: networking.ServerTLSSettings_PASSTHROUGH},
+			},
+			{
+				Name: "postgres",
+				Port: &networking.Port{Number: 5432, Protocol: "TCP"},
+			},
+		}},
+	}
+	tests := []struct {
+		name  string
+		input parser.Input
+		want  string
+	}{
+		{name: "http", input: parser.Input{Authority: "api.example.com"}, want: "http"},
+		{name: "authority with port", input: parser.Input{Authority: "api.example.com:80"}, want: "http"},
+		{name: "https scheme", input: parser.Input{Authority: "api.example.com", Scheme: "https"}, want: "https"},
+		{name: "https port", input: parser.Input{Authority: "api.example.com", Port: 443}, want: "https"},
+		{name: "unknown host", input: parser.Input{Authority: "www.example.com"}},
+		{name: "unknown port", input: parser.Input{Authority: "api.example.com", Port: 8080}},
+		{name: "https on the passthrough server", input: parser.Input{Authority: "api.payments.example.com", Scheme: "https"}},
+		{name: "tls", input: parser.Input{Protocol: parser.ProtocolTLS, SNI: "api.payments.example.com"}, want: "passthrough"},
+		{name: "tcp", input: parser.Input{Protocol: parser.ProtocolTCP, Port: 5432}, want: "postgres"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, selectServer(gw, tt.input).GetName())
+		})
+	}
+}
+
+func TestBoundVirtualServices(t *testing.T) {
+	gw := &v1.Gateway{ObjectMeta: metav1.ObjectMeta{Name: "public", Namespace: "istio-system"}}
+	server := &networking.Server{Hosts: []string{"shop/*.shop.example.com", "./api.example.com"}}
+	virtualService := func(namespace string, gateways ...string) *v1.VirtualService {
+		return &v1.VirtualService{
+			ObjectMeta: metav1.ObjectMeta{Name: namespace, Namespace: namespace},
+			Spec:       networking.VirtualService{Gateways: gateways},
+		}
+	}
+	shop := virtualService("shop", "istio-system/public")
+	unbound := virtualService("shop", "istio-system/internal")
+	other := virtualService("other", "istio-system/public")
+	local := virtualService("istio-system", "public")
+	virtualServices := []*v1.VirtualService{shop, unbound, other, local}
+
+	require.Equal(t, []*v1.VirtualService{shop}, boundVirtualServices(virtualServices, gw, server, "de.shop.example.com"))
+	require.Equal(t, []*v1.VirtualService{local}, boundVirtualServices(virtualServices, gw, server, "api.example.com:443"))
+}
+
+func TestRunGatewayRejection(t *testing.T) {
+	testcasefiles := []string{"testdata/gateway/virtualservice_test.yml"}
+	configfiles := []string{"../../../examples/gateway_virtualservice.yml"}
+	var strict bool
+	_, details, err := Run(testcasefiles, configfiles, strict)
+	require.ErrorContains(t, err, "gateway istio-system/public has no server accepting the request")
+	require.Contains(t, details, "FAIL input:[{internal.example.com GET / map[] map[]}]")
+}
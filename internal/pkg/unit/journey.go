@@ -0,0 +1,194 @@
+package unit
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+)
+
+// hop is a step of the journey of a request: the destination the route sends it to, with its fully qualified
+// host, the request the destination receives and the namespace of the route.
+type hop struct {
+	destination *networking.Destination
+	input       parser.Input
+	namespace   string
+}
+
+func (h hop) String() string {
+	out := h.destination.GetHost()
+	if subset := h.destination.GetSubset(); subset != "" {
+		out += "(" + subset + ")"
+	}
+	return out + " " + h.input.URI
+}
+
+// hops is the journey of a request.
+type hops []hop
+
+func (h hops) String() string {
+	var out []string
+	for _, hop := range h {
+		out = append(out, hop.String())
+	}
+	return "[" + strings.Join(out, " -> ") + "]"
+}
+
+// journey returns the hops of the request matching the route. The request is forwarded to the heaviest
+// destination of the route, rewritten, with the destination host as authority unless the rewrite sets one, then
+// routed again by the virtualservices of the mesh for its authority. The journey ends at a destination no
+// virtualservice reroutes, one routed to itself, e.g. to a subset, or when the request is not forwarded, e.g.
+// redirected. Journeys going back to a host fail.
+func journey(input parser.Input, route *networking.HTTPRoute, virtualServices []*v1.VirtualService) (hops, error) {
+	var out hops
+	for len(route.Route) > 0 && route.Redirect == nil && route.DirectResponse == nil {
+		destination := heaviestDestination(route)
+		namespace := routeNamespace(route, virtualServices)
+		host := parser.FQDN(destination.GetHost(), namespace)
+		if slices.ContainsFunc(out, func(h hop) bool { return h.destination.Host == host }) && out[len(out)-1].destination.Host != host {
+			return out, fmt.Errorf("journey loops back to %s: %v", host, out)
+		}
+		forwarded, err := forwardRequest(input, route)
+		if err != nil {
+			return out, err
+		}
+		if route.GetRewrite().GetAuthority() == "" {
+			forwarded.Authority = host
+		}
+		out = append(out, hop{
+			destination: &networking.Destination{Host: host, Subset: destination.GetSubset(), Port: destination.GetPort()},
+			input:       forwarded,
+			namespace:   namespace,
+		})
+		if len(out) > 1 && out[len(out)-2].destination.Host == host {
+			break
+		}
+		input = forwarded
+		checkHosts := true
+		route, err = GetRoute(input, meshVirtualServices(virtualServices), checkHosts)
+		if err != nil {
+			return out, err
+		}
+	}
+	return out, nil
+}
+
+// meshVirtualServices returns the virtualservices applying in the mesh, that is the ones bound to the sidecars.
+func meshVirtualServices(virtualServices []*v1.VirtualService) []*v1.VirtualService {
+	var out []*v1.VirtualService
+	for _, vs := range virtualServices {
+		if len(vs.Spec.Gateways) == 0 || slices.Contains(vs.Spec.Gateways, "mesh") {
+			out = append(out, vs)
+		}
+	}
+	return out
+}
+
+// forwardRequest returns the request as forwarded by the route: with the uri and authority rewritten and the
+// request headers of the route and of its heaviest destination applied.
+func forwardRequest(input parser.Input, route *networking.HTTPRoute) (parser.Input, error) {
+	out := input
+	out.Headers = maps.Clone(input.Headers)
+	// The destination receives the request from the mesh.
+	out.Gateway = ""
+	if rewrite := route.Rewrite; rewrite != nil {
+		switch {
+		case rewrite.UriRegexRewrite != nil:
+			re, err := regexp.Compile(rewrite.UriRegexRewrite.Match)
+			if err != nil {
+				return out, fmt.Errorf("invalid uriRegexRewrite match %q: %w", rewrite.UriRegexRewrite.Match, err)
+			}
+			out.URI = re.ReplaceAllString(input.URI, regexSubstitution(rewrite.UriRegexRewrite.Rewrite))
+		case strings.HasPrefix(rewrite.Uri, parser.PrefixReplacement):
+			out.URI = replacePrefix(input.URI, matchedPrefix(input, route), strings.TrimPrefix(rewrite.Uri, parser.PrefixReplacement))
+		case rewrite.Uri != "":
+			// Prefix matches only have their prefix rewritten, other matches the whole path.
+			out.URI = rewrite.Uri
+			if prefix := matchedPrefix(input, route); prefix != "" {
+				out.URI = rewrite.Uri + strings.TrimPrefix(input.URI, prefix)
+			}
+		}
+		if rewrite.Authority != "" {
+			out.Authority = rewrite.Authority
+		}
+	}
+	applyHeaders(&out, route.GetHeaders().GetRequest())
+	for _, destination := range route.Route {
+		if destination.GetDestination() == heaviestDestination(route) {
+			applyHeaders(&out, destination.GetHeaders().GetRequest())
+		}
+	}
+	return out, nil
+}
+
+// applyHeaders sets, appends and removes the request headers of the operations.
+func applyHeaders(input *parser.Input, operations *networking.Headers_HeaderOperations) {
+	if operations == nil {
+		return
+	}
+	if input.Headers == nil {
+		input.Headers = map[string]string{}
+	}
+	for name, value := range operations.Set {
+		input.Headers[strings.ToLower(name)] = value
+	}
+	for name, value := range operations.Add {
+		name = strings.ToLower(name)
+		if existing, ok := input.Headers[name]; ok {
+			value = existing + "," + value
+		}
+		input.Headers[name] = value
+	}
+	for _, name := range operations.Remove {
+		delete(input.Headers, strings.ToLower(name))
+	}
+}
+
+var regexGroup = regexp.MustCompile(`\\(\d)`)
+
+// regexSubstitution converts the groups of an Envoy regex substitution, e.g. \1, to Go ones.
+func regexSubstitution(rewrite string) string {
+	return regexGroup.ReplaceAllString(rewrite, "$${$1}")
+}
+
+// matchJourney returns true when the journey has the expected hops.
+func matchJourney(got hops, want []*parser.Hop) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i, h := range got {
+		w := want[i]
+		if !matchDestinationHost(h.destination.Host, w.Destination, h.namespace) {
+			return false
+		}
+		if w.Subset != "" && h.destination.Subset != w.Subset {
+			return false
+		}
+		if w.URI != "" && h.input.URI != w.URI {
+			return false
+		}
+		for name, value := range w.Headers {
+			if got, ok := h.input.Headers[strings.ToLower(name)]; !ok || got != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func describeJourney(hops []*parser.Hop) string {
+	var out []string
+	for _, h := range hops {
+		s := h.Destination
+		if h.Subset != "" {
+			s += "(" + h.Subset + ")"
+		}
+		out = append(out, strings.TrimSpace(s+" "+h.URI))
+	}
+	return "[" + strings.Join(out, " -> ") + "]"
+}
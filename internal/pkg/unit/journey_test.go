@@ -0,0 +1,163 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestJourney(t *testing.T) {
+	route := func(host string) *networking.HTTPRoute {
+		return &networking.HTTPRoute{Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: host}}}}
+	}
+	virtualService := func(name string, gateways []string, httpRoute *networking.HTTPRoute) *v1.VirtualService {
+		return &v1.VirtualService{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "shop"},
+			Spec:       networking.VirtualService{Hosts: []string{name}, Gateways: gateways, Http: []*networking.HTTPRoute{httpRoute}},
+		}
+	}
+	input := parser.Input{Authority: "shop.example.com", Method: "GET", URI: "/"}
+	tests := []struct {
+		name            string
+		route           *networking.HTTPRoute
+		virtualServices []*v1.VirtualService
+		want            string
+		wantErr         bool
+	}{
+		{
+			name:  "no virtualservice for the destination",
+			route: route("checkout.shop.svc.cluster.local"),
+			want:  "[checkout.shop.svc.cluster.local /]",
+		},
+		{
+			name:  "short destination hosts",
+			route: route("frontend"),
+			virtualServices: []*v1.VirtualService{
+				virtualService("frontend", nil, route("checkout")),
+				virtualService("checkout", []string{"mesh"}, route("payments")),
+			},
+			want: "[frontend.shop.svc.cluster.local / -> checkout.shop.svc.cluster.local / -> payments.shop.svc.cluster.local /]",
+		},
+		{
+			name: "rewritten authority",
+			route: &networking.HTTPRoute{
+				Rewrite: &networking.HTTPRewrite{Authority: "checkout"},
+				Route:   []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "frontend"}}},
+			},
+			virtualServices: []*v1.VirtualService{
+				virtualService("frontend", nil, route("reviews")),
+				virtualService("checkout", nil, route("payments")),
+			},
+			want: "[frontend.shop.svc.cluster.local / -> payments.shop.svc.cluster.local /]",
+		},
+		{
+			name:  "fully qualified virtualservice hosts",
+			route: route("frontend"),
+			virtualServices: []*v1.VirtualService{
+				virtualService("frontend.shop.svc.cluster.local", nil, route("checkout")),
+			},
+			want: "[frontend.shop.svc.cluster.local / -> checkout.shop.svc.cluster.local /]",
+		},
+		{
+			name:  "gateway virtualservices are not applied in the mesh",
+			route: route("frontend"),
+			virtualServices: []*v1.VirtualService{
+				virtualService("frontend", []string{"istio-system/public"}, route("checkout")),
+			},
+			want: "[frontend.shop.svc.cluster.local /]",
+		},
+		{
+			name:  "redirect",
+			route: &networking.HTTPRoute{Redirect: &networking.HTTPRedirect{Uri: "/home"}},
+			want:  "[]",
+		},
+		{
+			name:  "loop",
+			route: route("frontend"),
+			virtualServices: []*v1.VirtualService{
+				virtualService("frontend", nil, route("checkout")),
+				virtualService("checkout", nil, route("frontend")),
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			virtualServices := append(tt.virtualServices, virtualService("shop.example.com", []string{"istio-system/public"}, tt.route))
+			got, err := journey(input, tt.route, virtualServices)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestForwardRequest(t *testing.T) {
+	prefix := []*networking.HTTPMatchRequest{{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/shop"}}}}
+	input := parser.Input{Authority: "shop.example.com", Method: "GET", URI: "/shop/cart", Headers: map[string]string{"x-debug": "1", "x-trace": "a"}}
+	tests := []struct {
+		name  string
+		route *networking.HTTPRoute
+		want  parser.Input
+	}{
+		{
+			name:  "prefix rewrite",
+			route: &networking.HTTPRoute{Match: prefix, Rewrite: &networking.HTTPRewrite{Uri: "/v2", Authority: "frontend"}},
+			want:  parser.Input{Authority: "frontend", Method: "GET", URI: "/v2/cart", Headers: input.Headers},
+		},
+		{
+			name:  "gateway api prefix rewrite",
+			route: &networking.HTTPRoute{Match: prefix, Rewrite: &networking.HTTPRewrite{Uri: parser.PrefixReplacement + "/"}},
+			want:  parser.Input{Authority: "shop.example.com", Method: "GET", URI: "/cart", Headers: input.Headers},
+		},
+		{
+			name:  "gateway api prefix rewrite with a trailing slash",
+			route: &networking.HTTPRoute{Match: prefix, Rewrite: &networking.HTTPRewrite{Uri: parser.PrefixReplacement + "/v2/"}},
+			want:  parser.Input{Authority: "shop.example.com", Method: "GET", URI: "/v2/cart", Headers: input.Headers},
+		},
+		{
+			name:  "full path rewrite",
+			route: &networking.HTTPRoute{Rewrite: &networking.HTTPRewrite{Uri: "/"}},
+			want:  parser.Input{Authority: "shop.example.com", Method: "GET", URI: "/", Headers: input.Headers},
+		},
+		{
+			name:  "regex rewrite",
+			route: &networking.HTTPRoute{Rewrite: &networking.HTTPRewrite{UriRegexRewrite: &networking.RegexRewrite{Match: "^/shop/(.*)$", Rewrite: `/\1/v1`}}},
+			want:  parser.Input{Authority: "shop.example.com", Method: "GET", URI: "/cart/v1", Headers: input.Headers},
+		},
+		{
+			name: "headers",
+			route: &networking.HTTPRoute{
+				Headers: &networking.Headers{Request: &networking.Headers_HeaderOperations{
+					Set:    map[string]string{"X-Entrypoint": "shop"},
+					Add:    map[string]string{"x-trace": "b"},
+					Remove: []string{"x-debug"},
+				}},
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "frontend"},
+					Headers:     &networking.Headers{Request: &networking.Headers_HeaderOperations{Set: map[string]string{"x-version": "v2"}}},
+				}},
+			},
+			want: parser.Input{Authority: "shop.example.com", Method: "GET", URI: "/shop/cart", Headers: map[string]string{
+				"x-entrypoint": "shop",
+				"x-trace":      "a,b",
+				"x-version":    "v2",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := forwardRequest(input, tt.route)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+	require.Equal(t, map[string]string{"x-debug": "1", "x-trace": "a"}, input.Headers)
+}
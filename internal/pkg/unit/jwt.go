@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/authz"
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+	security "istio.io/api/security/v1"
+	securityv1 "istio.io/client-go/pkg/apis/security/v1"
+)
+
+// claimPrefix prefixes the header names of header matches on the claims of the request token.
+const claimPrefix = "@request.auth.claims."
+
+// authenticate returns the claims of the token of a request received by the workload, e.g. a gateway, once
+// validated by the requestauthentications applying to the workload: its issuer must be the one of a jwt rule, its
+// audience one of the rule audiences when the rule has some, and it must not be expired. Signatures are not
+// verified. Tokens are ignored, and no claims are returned, when no requestauthentication applies. It returns
+// false when the token is rejected.
+func authenticate(requestAuthentications []*securityv1.RequestAuthentication, workload parser.Workload, token string, now time.Time) (map[string]any, bool) {
+	if token == "" {
+		return nil, true
+	}
+	var rules []*security.JWTRule
+	for _, ra := range requestAuthentications {
+		if authz.Selects(ra.Namespace, ra.Spec.GetSelector().GetMatchLabels(), workload) {
+			rules = append(rules, ra.Spec.JwtRules...)
+		}
+	}
+	if len(rules) == 0 {
+		return nil, true
+	}
+	claims, err := parser.DecodeClaims(token)
+	if err != nil {
+		return nil, false
+	}
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0)) {
+		return nil, false
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Before(time.Unix(int64(nbf), 0)) {
+		return nil, false
+	}
+	issuer, _ := claims["iss"].(string)
+	audiences := claimValues(claims, "aud")
+	for _, rule := range rules {
+		if rule.Issuer != issuer {
+			continue
+		}
+		if len(rule.Audiences) == 0 || slices.ContainsFunc(audiences, func(aud string) bool { return slices.Contains(rule.Audiences, aud) }) {
+			return claims, true
+		}
+	}
+	return nil, false
+}
+
+// claimValues returns the values of a claim, given as a path of nested claims separated by dots. List claims,
+// e.g. groups, have a value per item.
+func claimValues(claims map[string]any, path string) []string {
+	var value any = claims
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if value, ok = object[name]; !ok {
+			return nil
+		}
+	}
+	switch value := value.(type) {
+	case []any:
+		var out []string
+		for _, item := range value {
+			out = append(out, fmt.Sprint(item))
+		}
+		return out
+	case map[string]any:
+		return nil
+	}
+	return []string{fmt.Sprint(value)}
+}
+
+// headerValues returns the values a header match is evaluated against: the value of the request header, or the
+// values of the claim for "@request.auth.claims." names. It returns false when the header or claim is missing.
+func headerValues(input parser.Input, name string) ([]string, bool) {
+	if claim, ok := strings.CutPrefix(name, claimPrefix); ok {
+		values := claimValues(input.Claims, claim)
+		return values, len(values) > 0
+	}
+	value, ok := input.Headers[name]
+	return []string{value}, ok
+}
+
+// bearerHeaders returns the headers of a request with its token in the authorization header, unless the request
+// sets its own.
+func bearerHeaders(headers map[string]string, token string) map[string]string {
+	if _, ok := headers["authorization"]; ok {
+		return headers
+	}
+	out := maps.Clone(headers)
+	if out == nil {
+		out = map[string]string{}
+	}
+	out["authorization"] = "Bearer " + token
+	return out
+}
+
+// unauthorizedRoute is the route of requests whose token is rejected.
+func unauthorizedRoute() *networking.HTTPRoute {
+	return &networking.HTTPRoute{DirectResponse: &networking.HTTPDirectResponse{Status: 401}}
+}
@@ -0,0 +1,128 @@
+package unit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+	security "istio.io/api/security/v1"
+	typev1beta1 "istio.io/api/type/v1beta1"
+	securityv1 "istio.io/client-go/pkg/apis/security/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAuthenticate(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	gateway := parser.Workload{Namespace: "istio-system", Labels: map[string]string{"istio": "ingressgateway"}}
+	requestAuthentication := func(selector map[string]string, rules ...*security.JWTRule) *securityv1.RequestAuthentication {
+		return &securityv1.RequestAuthentication{
+			ObjectMeta: metav1.ObjectMeta{Name: "jwt", Namespace: "istio-system"},
+			Spec: security.RequestAuthentication{
+				Selector: &typev1beta1.WorkloadSelector{MatchLabels: selector},
+				JwtRules: rules,
+			},
+		}
+	}
+	gatewayRules := []*securityv1.RequestAuthentication{
+		requestAuthentication(map[string]string{"istio": "ingressgateway"},
+			&security.JWTRule{Issuer: "https://auth.example.com", Audiences: []string{"api"}},
+			&security.JWTRule{Issuer: "https://accounts.example.com"},
+		),
+	}
+	tests := []struct {
+		name      string
+		ras       []*securityv1.RequestAuthentication
+		claims    map[string]any
+		wantValid bool
+		wantClaim bool
+	}{
+		{name: "no token", ras: gatewayRules, wantValid: true},
+		{name: "issuer and audience", ras: gatewayRules, claims: map[string]any{"iss": "https://auth.example.com", "aud": "api"}, wantValid: true, wantClaim: true},
+		{name: "audience list", ras: gatewayRules, claims: map[string]any{"iss": "https://auth.example.com", "aud": []any{"web", "api"}}, wantValid: true, wantClaim: true},
+		{name: "rule without audiences", ras: gatewayRules, claims: map[string]any{"iss": "https://accounts.example.com", "aud": "web"}, wantValid: true, wantClaim: true},
+		{name: "unknown issuer", ras: gatewayRules, claims: map[string]any{"iss": "https://evil.example.com", "aud": "api"}},
+		{name: "unknown audience", ras: gatewayRules, claims: map[string]any{"iss": "https://auth.example.com", "aud": "web"}},
+		{name: "expired", ras: gatewayRules, claims: map[string]any{"iss": "https://auth.example.com", "aud": "api", "exp": 1600000000}},
+		{name: "not valid yet", ras: gatewayRules, claims: map[string]any{"iss": "https://auth.example.com", "aud": "api", "nbf": 1800000000}},
+		{
+			name:      "other workload",
+			ras:       []*securityv1.RequestAuthentication{requestAuthentication(map[string]string{"istio": "egressgateway"}, &security.JWTRule{Issuer: "https://auth.example.com"})},
+			claims:    map[string]any{"iss": "https://evil.example.com"},
+			wantValid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var token string
+			if tt.claims != nil {
+				var err error
+				token, err = parser.SignClaims(tt.claims)
+				require.NoError(t, err)
+			}
+			claims, valid := authenticate(tt.ras, gateway, token, now)
+			require.Equal(t, tt.wantValid, valid)
+			require.Equal(t, tt.wantClaim, claims != nil)
+		})
+	}
+}
+
+func TestBearerHeaders(t *testing.T) {
+	headers := map[string]string{"x-tenant": "acme"}
+	require.Equal(t, map[string]string{"x-tenant": "acme", "authorization": "Bearer a.b.c"}, bearerHeaders(headers, "a.b.c"))
+	require.Equal(t, map[string]string{"x-tenant": "acme"}, headers)
+	require.Equal(t, map[string]string{"authorization": "Bearer a.b.c"}, bearerHeaders(nil, "a.b.c"))
+
+	own := map[string]string{"authorization": "Basic YWNtZQ=="}
+	require.Equal(t, own, bearerHeaders(own, "a.b.c"))
+}
+
+func TestMatchRequestClaims(t *testing.T) {
+	input := parser.Input{
+		Authority: "api.example.com",
+		Method:    "GET",
+		URI:       "/",
+		Claims: map[string]any{
+			"tenant": "acme",
+			"groups": []any{"admins", "beta"},
+			"org":    map[string]any{"plan": "enterprise"},
+		},
+	}
+	tests := []struct {
+		name  string
+		match *networking.HTTPMatchRequest
+		want  bool
+	}{
+		{
+			name:  "claim",
+			match: &networking.HTTPMatchRequest{Headers: map[string]*networking.StringMatch{"@request.auth.claims.tenant": {MatchType: &networking.StringMatch_Exact{Exact: "acme"}}}},
+			want:  true,
+		},
+		{
+			name:  "list claim item",
+			match: &networking.HTTPMatchRequest{Headers: map[string]*networking.StringMatch{"@request.auth.claims.groups": {MatchType: &networking.StringMatch_Exact{Exact: "beta"}}}},
+			want:  true,
+		},
+		{
+			name:  "nested claim",
+			match: &networking.HTTPMatchRequest{Headers: map[string]*networking.StringMatch{"@request.auth.claims.org.plan": {MatchType: &networking.StringMatch_Prefix{Prefix: "enter"}}}},
+			want:  true,
+		},
+		{
+			name:  "missing claim",
+			match: &networking.HTTPMatchRequest{Headers: map[string]*networking.StringMatch{"@request.auth.claims.role": {MatchType: &networking.StringMatch_Exact{Exact: "admin"}}}},
+		},
+		{
+			name:  "without claim",
+			match: &networking.HTTPMatchRequest{WithoutHeaders: map[string]*networking.StringMatch{"@request.auth.claims.groups": {MatchType: &networking.StringMatch_Exact{Exact: "admins"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchRequest(input, tt.match)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
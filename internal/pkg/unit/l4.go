@@ -0,0 +1,126 @@
+package unit
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+)
+
+// getL4Route returns the tls or tcp route matching the input, as an HTTPRoute holding its destinations so the
+// route assertions apply to it. Tls requests are matched against the virtualservices hosts by SNI, tcp ones
+// by port and destination address only. Matches restricted to some gateways only apply to the requests they
+// route, see matchGateways.
+// TODO: Add support for sourceLabels and sourceNamespace.
+func getL4Route(input parser.Input, virtualServices []*v1.VirtualService, checkHosts bool) (*networking.HTTPRoute, error) {
+	for _, vs := range virtualServices {
+		spec := &vs.Spec
+		switch input.Protocol {
+		case parser.ProtocolTLS:
+			if checkHosts && !slices.ContainsFunc(spec.Hosts, func(host string) bool { return parser.MatchHost(input.SNI, host) }) {
+				continue
+			}
+			for _, tlsRoute := range spec.Tls {
+				for _, matchBlock := range tlsRoute.Match {
+					if !matchGateways(input, vs.Namespace, matchBlock.Gateways) {
+						continue
+					}
+					match, err := matchTLS(input, matchBlock)
+					if err != nil {
+						return &networking.HTTPRoute{}, err
+					}
+					if match {
+						return &networking.HTTPRoute{Route: convertRouteDestinations(tlsRoute.Route)}, nil
+					}
+				}
+			}
+		case parser.ProtocolTCP:
+			for _, tcpRoute := range spec.Tcp {
+				if len(tcpRoute.Match) == 0 {
+					return &networking.HTTPRoute{Route: convertRouteDestinations(tcpRoute.Route)}, nil
+				}
+				for _, matchBlock := range tcpRoute.Match {
+					if !matchGateways(input, vs.Namespace, matchBlock.Gateways) {
+						continue
+					}
+					match, err := matchL4(input, matchBlock.Port, matchBlock.DestinationSubnets)
+					if err != nil {
+						return &networking.HTTPRoute{}, err
+					}
+					if match {
+						return &networking.HTTPRoute{Route: convertRouteDestinations(tcpRoute.Route)}, nil
+					}
+				}
+			}
+		}
+	}
+	return &networking.HTTPRoute{}, nil
+}
+
+// matchGateways returns true when the gateways of a match block, if any, include the one routing the request, or
+// "mesh" for requests routed in the mesh. Gateway references without namespace are in the namespace of the
+// virtualservice.
+func matchGateways(input parser.Input, namespace string, gateways []string) bool {
+	if len(gateways) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(gateways, func(ref string) bool {
+		if ref == "mesh" {
+			return input.Gateway == ""
+		}
+		if !strings.Contains(ref, "/") {
+			ref = namespace + "/" + ref
+		}
+		return ref == input.Gateway
+	})
+}
+
+// matchTLS returns true when the input SNI matches one of the sniHosts, and its port and address match.
+func matchTLS(input parser.Input, matchBlock *networking.TLSMatchAttributes) (bool, error) {
+	if len(matchBlock.SniHosts) > 0 && !slices.ContainsFunc(matchBlock.SniHosts, func(host string) bool { return parser.MatchHost(input.SNI, host) }) {
+		return false, nil
+	}
+	return matchL4(input, matchBlock.Port, matchBlock.DestinationSubnets)
+}
+
+// matchL4 returns true when the input port and destination address match the ones of a tls or tcp match block.
+// An unset port or empty subnet list matches any request.
+func matchL4(input parser.Input, port uint32, destinationSubnets []string) (bool, error) {
+	if port != 0 && input.Port != port {
+		return false, nil
+	}
+	if len(destinationSubnets) == 0 {
+		return true, nil
+	}
+	address, err := netip.ParseAddr(input.DestinationIP)
+	if err != nil {
+		// Requests without destination address do not match rules restricted to some subnets.
+		return false, nil
+	}
+	for _, subnet := range destinationSubnets {
+		// Subnets may be given as a single address.
+		if !strings.Contains(subnet, "/") {
+			subnet += fmt.Sprintf("/%d", address.BitLen())
+		}
+		prefix, err := netip.ParsePrefix(subnet)
+		if err != nil {
+			return false, fmt.Errorf("invalid destination subnet %q: %w", subnet, err)
+		}
+		if prefix.Contains(address) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func convertRouteDestinations(destinations []*networking.RouteDestination) []*networking.HTTPRouteDestination {
+	var out []*networking.HTTPRouteDestination
+	for _, destination := range destinations {
+		out = append(out, &networking.HTTPRouteDestination{Destination: destination.Destination, Weight: destination.Weight})
+	}
+	return out
+}
@@ -0,0 +1,85 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetL4Route(t *testing.T) {
+	destination := func(host string) []*networking.RouteDestination {
+		return []*networking.RouteDestination{{Destination: &networking.Destination{Host: host}}}
+	}
+	virtualServices := []*v1.VirtualService{{
+		Spec: networking.VirtualService{
+			Hosts: []string{"*.example.com"},
+			Tls: []*networking.TLSRoute{{
+				Match: []*networking.TLSMatchAttributes{{SniHosts: []string{"api.example.com"}, Port: 443}},
+				Route: destination("api"),
+			}, {
+				Match: []*networking.TLSMatchAttributes{{SniHosts: []string{"*.example.com"}}},
+				Route: destination("web"),
+			}},
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Namespace: "example"},
+		Spec: networking.VirtualService{
+			Hosts: []string{"redis.example.com"},
+			Tcp: []*networking.TCPRoute{{
+				Match: []*networking.L4MatchAttributes{{Port: 6379, Gateways: []string{"public"}}},
+				Route: destination("redis-external"),
+			}, {
+				Match: []*networking.L4MatchAttributes{{Port: 6379, Gateways: []string{"mesh"}}},
+				Route: destination("redis"),
+			}},
+		},
+	}, {
+		Spec: networking.VirtualService{
+			Hosts: []string{"db.example.com"},
+			Tcp: []*networking.TCPRoute{{
+				Match: []*networking.L4MatchAttributes{{Port: 5432, DestinationSubnets: []string{"10.10.0.0/16", "10.20.0.1"}}},
+				Route: destination("primary"),
+			}, {
+				Route: destination("replica"),
+			}},
+		},
+	}}
+	tests := []struct {
+		name  string
+		input parser.Input
+		want  string
+	}{
+		{name: "sni and port", input: parser.Input{Protocol: parser.ProtocolTLS, SNI: "api.example.com", Port: 443}, want: "api"},
+		{name: "sni on another port", input: parser.Input{Protocol: parser.ProtocolTLS, SNI: "api.example.com", Port: 8443}, want: "web"},
+		{name: "wildcard sni", input: parser.Input{Protocol: parser.ProtocolTLS, SNI: "www.example.com", Port: 443}, want: "web"},
+		{name: "unknown sni", input: parser.Input{Protocol: parser.ProtocolTLS, SNI: "www.example.org", Port: 443}, want: ""},
+		{name: "subnet", input: parser.Input{Protocol: parser.ProtocolTCP, Port: 5432, DestinationIP: "10.10.3.4"}, want: "primary"},
+		{name: "single address", input: parser.Input{Protocol: parser.ProtocolTCP, Port: 5432, DestinationIP: "10.20.0.1"}, want: "primary"},
+		{name: "other subnet", input: parser.Input{Protocol: parser.ProtocolTCP, Port: 5432, DestinationIP: "10.30.0.1"}, want: "replica"},
+		{name: "no address", input: parser.Input{Protocol: parser.ProtocolTCP, Port: 5432}, want: "replica"},
+		{name: "mesh", input: parser.Input{Protocol: parser.ProtocolTCP, Port: 6379}, want: "redis"},
+		{name: "through the gateway", input: parser.Input{Protocol: parser.ProtocolTCP, Port: 6379, Gateway: "example/public"}, want: "redis-external"},
+		{name: "through another gateway", input: parser.Input{Protocol: parser.ProtocolTCP, Port: 6379, Gateway: "istio-system/public"}, want: "replica"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkHosts := true
+			route, err := GetRoute(tt.input, virtualServices, checkHosts)
+			require.NoError(t, err)
+			var got string
+			if len(route.Route) > 0 {
+				got = route.Route[0].Destination.Host
+			}
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMatchL4InvalidSubnet(t *testing.T) {
+	_, err := matchL4(parser.Input{DestinationIP: "10.0.0.1"}, 0, []string{"10.0.0.0/33"})
+	require.ErrorContains(t, err, "invalid destination subnet")
+}
@@ -43,21 +43,71 @@ func (sm *ExtendedStringMatch) Match(s string) (bool, error) {
 	return false, nil
 }
 
+// matchAny returns true when any of the values matches the StringMatch, e.g. an item of a list claim.
+func matchAny(sm *ExtendedStringMatch, values []string) (bool, error) {
+	for _, value := range values {
+		match, err := sm.Match(value)
+		if err != nil || match {
+			return match, err
+		}
+	}
+	return false, nil
+}
+
 // matchRequest takes an Input and evaluates against a HTTPMatchRequest block. It replicates
 // Istio VirtualService semantic returning true when ALL conditions within the block are true.
 // TODO: Add support for all fields within a match block. The ones supported today are:
-// Authority, Uri, Method and Headers.
+// Authority, Uri, Method, Headers, WithoutHeaders and QueryParams. Headers named "@request.auth.claims.<claim>"
+// match the claims of the request token.
 func matchRequest(input parser.Input, httpMatchRequest *v1alpha3.HTTPMatchRequest) (bool, error) {
 	authority := &ExtendedStringMatch{httpMatchRequest.Authority}
 	uri := &ExtendedStringMatch{httpMatchRequest.Uri}
 	method := &ExtendedStringMatch{httpMatchRequest.Method}
 
 	for headerName, sm := range httpMatchRequest.Headers {
-		if _, ok := input.Headers[headerName]; !ok {
+		values, ok := headerValues(input, headerName)
+		if !ok {
+			return false, nil
+		}
+		match, err := matchAny(&ExtendedStringMatch{sm}, values)
+		if err != nil {
+			return false, err
+		}
+		if !match {
+			return false, nil
+		}
+	}
+
+	// The rule does not match when any of the headers in withoutHeaders is present and matches; an empty match
+	// only requires the header to be present.
+	for headerName, sm := range httpMatchRequest.WithoutHeaders {
+		values, ok := headerValues(input, headerName)
+		if !ok {
+			continue
+		}
+		if sm.GetExact() == "" && sm.GetPrefix() == "" && sm.GetRegex() == "" {
 			return false, nil
 		}
-		header := &ExtendedStringMatch{sm}
-		match, err := header.Match(input.Headers[headerName])
+		match, err := matchAny(&ExtendedStringMatch{sm}, values)
+		if err != nil {
+			return false, err
+		}
+		if match {
+			return false, nil
+		}
+	}
+
+	for name, sm := range httpMatchRequest.QueryParams {
+		value, ok := input.Query[name]
+		if !ok {
+			return false, nil
+		}
+		// An empty match only requires the query parameter to be present.
+		if sm.GetExact() == "" && sm.GetPrefix() == "" && sm.GetRegex() == "" {
+			continue
+		}
+		queryParam := &ExtendedStringMatch{sm}
+		match, err := queryParam.Match(value)
 		if err != nil {
 			return false, err
 		}
@@ -315,6 +315,97 @@ func Test_matchRequest(t *testing.T) {
 		},
 		want:    false,
 		wantErr: false,
+	}, {
+		name: "match query params exact, regex and presence (true)",
+		args: args{
+			input: parser.Input{Authority: "www.example.com", URI: "/", Method: "GET", Query: map[string]string{
+				"lang":     "en",
+				"page":     "12",
+				"campaign": "",
+			}},
+			httpMatchRequest: &networkingv1alpha3.HTTPMatchRequest{
+				QueryParams: map[string]*networkingv1alpha3.StringMatch{
+					"lang": {
+						MatchType: &networkingv1alpha3.StringMatch_Exact{
+							Exact: "en",
+						},
+					},
+					"page": {
+						MatchType: &networkingv1alpha3.StringMatch_Regex{
+							Regex: "[0-9]+",
+						},
+					},
+					"campaign": {},
+				},
+			},
+		},
+		want:    true,
+		wantErr: false,
+	}, {
+		name: "match missing query param (false)",
+		args: args{
+			input: parser.Input{Authority: "www.example.com", URI: "/", Method: "GET", Query: map[string]string{
+				"lang": "en",
+			}},
+			httpMatchRequest: &networkingv1alpha3.HTTPMatchRequest{
+				QueryParams: map[string]*networkingv1alpha3.StringMatch{
+					"campaign": {},
+				},
+			},
+		},
+		want:    false,
+		wantErr: false,
+	}, {
+		name: "without headers, header absent or not matching (true)",
+		args: args{
+			input: parser.Input{Authority: "www.example.com", URI: "/", Method: "GET", Headers: map[string]string{
+				"x-user-type": "customer",
+			}},
+			httpMatchRequest: &networkingv1alpha3.HTTPMatchRequest{
+				WithoutHeaders: map[string]*networkingv1alpha3.StringMatch{
+					"x-user-type": {
+						MatchType: &networkingv1alpha3.StringMatch_Regex{
+							Regex: "qa|beta",
+						},
+					},
+					"x-debug": {},
+				},
+			},
+		},
+		want:    true,
+		wantErr: false,
+	}, {
+		name: "without headers, header present (false)",
+		args: args{
+			input: parser.Input{Authority: "www.example.com", URI: "/", Method: "GET", Headers: map[string]string{
+				"x-debug": "1",
+			}},
+			httpMatchRequest: &networkingv1alpha3.HTTPMatchRequest{
+				WithoutHeaders: map[string]*networkingv1alpha3.StringMatch{
+					"x-debug": {},
+				},
+			},
+		},
+		want:    false,
+		wantErr: false,
+	}, {
+		name: "without headers, header matching (false)",
+		args: args{
+			input: parser.Input{Authority: "www.example.com", URI: "/", Method: "GET", Headers: map[string]string{
+				"x-user-type": "beta",
+			}},
+			httpMatchRequest: &networkingv1alpha3.HTTPMatchRequest{
+				WithoutHeaders: map[string]*networkingv1alpha3.StringMatch{
+					"x-user-type": {
+						MatchType: &networkingv1alpha3.StringMatch_Regex{
+							Regex: "qa|beta",
+						},
+					},
+				},
+			},
+		},
+		want:    false,
+		wantErr: false,
 	}}
 
 	for _, tt := range tests {
@@ -0,0 +1,115 @@
+package unit
+
+import (
+	"fmt"
+	"strings"
+
+	meshconfig "istio.io/api/mesh/v1alpha1"
+)
+
+// PathNormalization is the normalization applied by the proxies to request paths before matching them.
+type PathNormalization = meshconfig.MeshConfig_ProxyPathNormalization_NormalizationType
+
+// ParsePathNormalization returns the path normalization with the given name, as found in the mesh config. An
+// empty name is the default normalization.
+func ParsePathNormalization(name string) (PathNormalization, error) {
+	value, ok := meshconfig.MeshConfig_ProxyPathNormalization_NormalizationType_value[strings.ToUpper(name)]
+	if name != "" && !ok {
+		return meshconfig.MeshConfig_ProxyPathNormalization_DEFAULT, fmt.Errorf("unknown path normalization %q", name)
+	}
+	return PathNormalization(value), nil
+}
+
+// NormalizePath normalizes the request path the way Envoy does for the given mesh path normalization:
+//   - BASE, the default, resolves "." and ".." segments, turns backslashes into slashes and decodes the
+//     percent-encoded unreserved characters, as Envoy's normalize_path.
+//   - MERGE_SLASHES also merges consecutive slashes.
+//   - DECODE_AND_MERGE_SLASHES also decodes "%2F" and "%5C" beforehand.
+func NormalizePath(path string, normalization PathNormalization) string {
+	if normalization == meshconfig.MeshConfig_ProxyPathNormalization_NONE || !strings.HasPrefix(path, "/") {
+		return path
+	}
+	if normalization == meshconfig.MeshConfig_ProxyPathNormalization_DECODE_AND_MERGE_SLASHES {
+		path = decodeSlashes(path)
+	}
+	path = removeDotSegments(decodeUnreserved(strings.ReplaceAll(path, `\`, "/")))
+	if normalization == meshconfig.MeshConfig_ProxyPathNormalization_MERGE_SLASHES ||
+		normalization == meshconfig.MeshConfig_ProxyPathNormalization_DECODE_AND_MERGE_SLASHES {
+		path = mergeSlashes(path)
+	}
+	return path
+}
+
+func decodeSlashes(path string) string {
+	return strings.NewReplacer("%2F", "/", "%2f", "/", "%5C", `\`, "%5c", `\`).Replace(path)
+}
+
+// decodeUnreserved decodes the percent-encoded unreserved characters (RFC 3986 section 2.3) and upper cases
+// the other percent-encodings.
+func decodeUnreserved(path string) string {
+	var out strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] != '%' || i+2 >= len(path) || !isHex(path[i+1]) || !isHex(path[i+2]) {
+			out.WriteByte(path[i])
+			continue
+		}
+		c := unhex(path[i+1])<<4 | unhex(path[i+2])
+		if isUnreserved(c) {
+			out.WriteByte(c)
+		} else {
+			out.WriteString(strings.ToUpper(path[i : i+3]))
+		}
+		i += 2
+	}
+	return out.String()
+}
+
+// removeDotSegments resolves the "." and ".." segments (RFC 3986 section 5.2.4). Empty segments are kept.
+func removeDotSegments(path string) string {
+	segments := strings.Split(path, "/")[1:]
+	var out []string
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+			continue
+		}
+		// A trailing dot segment leaves the path ending with a slash.
+		if last {
+			out = append(out, "")
+		}
+	}
+	return "/" + strings.Join(out, "/")
+}
+
+func mergeSlashes(path string) string {
+	for strings.Contains(path, "//") {
+		path = strings.ReplaceAll(path, "//", "/")
+	}
+	return path
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	}
+	return c - 'A' + 10
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
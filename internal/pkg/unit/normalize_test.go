@@ -0,0 +1,48 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	meshconfig "istio.io/api/mesh/v1alpha1"
+)
+
+func TestNormalizePath(t *testing.T) {
+	tests := []struct {
+		path          string
+		normalization PathNormalization
+		want          string
+	}{
+		{"//users/../admin", meshconfig.MeshConfig_ProxyPathNormalization_NONE, "//users/../admin"},
+		{"//users/../admin", meshconfig.MeshConfig_ProxyPathNormalization_DEFAULT, "//admin"},
+		{"//users/../admin", meshconfig.MeshConfig_ProxyPathNormalization_BASE, "//admin"},
+		{"//users/../admin", meshconfig.MeshConfig_ProxyPathNormalization_MERGE_SLASHES, "/admin"},
+		{"/a/./b/../../c/", meshconfig.MeshConfig_ProxyPathNormalization_BASE, "/c/"},
+		{"/a/b/..", meshconfig.MeshConfig_ProxyPathNormalization_BASE, "/a/"},
+		{"/../..", meshconfig.MeshConfig_ProxyPathNormalization_BASE, "/"},
+		{`/a\b`, meshconfig.MeshConfig_ProxyPathNormalization_BASE, "/a/b"},
+		{"/%7Eusers/%2e%2e/a%2fb%3f", meshconfig.MeshConfig_ProxyPathNormalization_BASE, "/a%2Fb%3F"},
+		{"/a%2f%2Fb%5cc", meshconfig.MeshConfig_ProxyPathNormalization_MERGE_SLASHES, "/a%2F%2Fb%5Cc"},
+		{"/a%2f%2Fb%5cc", meshconfig.MeshConfig_ProxyPathNormalization_DECODE_AND_MERGE_SLASHES, "/a/b/c"},
+		{"/%zz%4", meshconfig.MeshConfig_ProxyPathNormalization_BASE, "/%zz%4"},
+		{"*", meshconfig.MeshConfig_ProxyPathNormalization_BASE, "*"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.normalization.String()+" "+tt.path, func(t *testing.T) {
+			require.Equal(t, tt.want, NormalizePath(tt.path, tt.normalization))
+		})
+	}
+}
+
+func TestParsePathNormalization(t *testing.T) {
+	got, err := ParsePathNormalization("merge_slashes")
+	require.NoError(t, err)
+	require.Equal(t, meshconfig.MeshConfig_ProxyPathNormalization_MERGE_SLASHES, got)
+
+	got, err = ParsePathNormalization("")
+	require.NoError(t, err)
+	require.Equal(t, meshconfig.MeshConfig_ProxyPathNormalization_DEFAULT, got)
+
+	_, err = ParsePathNormalization("resolve")
+	require.ErrorContains(t, err, `unknown path normalization "resolve"`)
+}
@@ -0,0 +1,34 @@
+package unit
+
+import "time"
+
+type options struct {
+	pathNormalization PathNormalization
+	checkDestinations bool
+	now               time.Time
+}
+
+type optionFunc func(*options)
+
+// WithPathNormalization sets the path normalization applied to the test case requests which do not set their own.
+func WithPathNormalization(normalization PathNormalization) optionFunc {
+	return func(o *options) {
+		o.pathNormalization = normalization
+	}
+}
+
+// WithDestinationCheck fails the run when a destination of the virtualservices does not resolve to a kubernetes
+// service or service entry of the config, or to one of their ports.
+func WithDestinationCheck(check bool) optionFunc {
+	return func(o *options) {
+		o.checkDestinations = check
+	}
+}
+
+// WithNow sets the time the expiration and not before claims of request tokens are checked against, the start of
+// the run by default.
+func WithNow(now time.Time) optionFunc {
+	return func(o *options) {
+		o.now = now
+	}
+}
@@ -0,0 +1,127 @@
+package unit
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+)
+
+// maxRedirects is the number of redirects followed before giving up, as browsers do.
+const maxRedirects = 20
+
+// redirectChain is the urls of a request followed through its redirects, starting with the request itself.
+type redirectChain []string
+
+func (c redirectChain) String() string {
+	count := fmt.Sprintf("%d redirects", len(c)-1)
+	if len(c) == 2 {
+		count = "1 redirect"
+	}
+	return strings.Join(c, " -> ") + " (" + count + ")"
+}
+
+// followRedirects follows the redirects of the request matching the route, re-issuing the request to their
+// location, and returns the chain of urls along with the route the request lands on and the request as matched
+// by it. Chains going back to a url, or longer than maxRedirects, fail.
+func followRedirects(router requestRouter, input parser.Input, route *networking.HTTPRoute) (redirectChain, *networking.HTTPRoute, parser.Input, error) {
+	chain := redirectChain{requestURL(input)}
+	for {
+		redirect, err := resolveDelegate(input, route, router.virtualServices)
+		if err != nil {
+			return chain, route, input, err
+		}
+		if redirect.Redirect == nil {
+			return chain, route, input, nil
+		}
+		location := redirectLocation(input, redirect)
+		if slices.Contains(chain, location) {
+			return chain, route, input, fmt.Errorf("redirect loop: %v", append(chain, location))
+		}
+		chain = append(chain, location)
+		if len(chain) > maxRedirects+1 {
+			return chain, route, input, fmt.Errorf("more than %d redirects: %v", maxRedirects, chain)
+		}
+		next, err := redirectedRequest(input, location, redirect.Redirect.RedirectCode)
+		if err != nil {
+			return chain, route, input, err
+		}
+		if route, input, err = router.route(next); err != nil {
+			return chain, route, input, fmt.Errorf("following redirect to %s: %w", location, err)
+		}
+	}
+}
+
+// resolveDelegate returns the route of the delegated virtualservice matching the request when the route
+// delegates, the route itself otherwise.
+func resolveDelegate(input parser.Input, route *networking.HTTPRoute, virtualServices []*v1.VirtualService) (*networking.HTTPRoute, error) {
+	if route.Delegate == nil {
+		return route, nil
+	}
+	vs, err := GetDelegatedVirtualService(route.Delegate, virtualServices)
+	if err != nil {
+		return nil, fmt.Errorf("error getting delegate virtual service: %w", err)
+	}
+	checkHosts := false
+	return GetRoute(input, []*v1.VirtualService{vs}, checkHosts)
+}
+
+// requestURL returns the url of the request, its scheme defaulting to the one of its listener.
+func requestURL(input parser.Input) string {
+	scheme, port := listener(input)
+	out := url.URL{Scheme: scheme, Host: input.Authority, Path: input.URI}
+	if input.Port != 0 && !(scheme == "http" && port == 80) && !(scheme == "https" && port == 443) {
+		out.Host += ":" + strconv.Itoa(int(port))
+	}
+	if len(input.Query) > 0 {
+		query := url.Values{}
+		for name, value := range input.Query {
+			query.Set(name, value)
+		}
+		out.RawQuery = query.Encode()
+	}
+	return out.String()
+}
+
+// redirectedRequest returns the request a client sends to the location it is redirected to. As browsers do,
+// the method becomes GET on a 303, and POST requests become GET ones on a 301 or 302.
+func redirectedRequest(input parser.Input, location string, code uint32) (parser.Input, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return input, fmt.Errorf("invalid redirect location %q: %w", location, err)
+	}
+	out := input
+	out.Scheme = u.Scheme
+	out.Authority = u.Hostname()
+	out.Port = 0
+	if port := u.Port(); port != "" {
+		number, err := strconv.ParseUint(port, 10, 32)
+		if err != nil {
+			return input, fmt.Errorf("invalid redirect location %q: %w", location, err)
+		}
+		out.Port = uint32(number)
+	}
+	out.URI = u.EscapedPath()
+	// Claims are the ones validated for the previous request.
+	out.Claims = nil
+	out.Query = nil
+	for name, values := range u.Query() {
+		if out.Query == nil {
+			out.Query = map[string]string{}
+		}
+		out.Query[name] = values[0]
+	}
+	switch {
+	case code == http.StatusSeeOther:
+		out.Method = http.MethodGet
+	case (code == 0 || code == http.StatusMovedPermanently || code == http.StatusFound) && input.Method == http.MethodPost:
+		out.Method = http.MethodGet
+	}
+	return out, nil
+}
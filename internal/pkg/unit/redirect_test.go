@@ -0,0 +1,100 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFollowRedirects(t *testing.T) {
+	exact := func(uri string) []*networking.HTTPMatchRequest {
+		return []*networking.HTTPMatchRequest{{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: uri}}}}
+	}
+	tests := []struct {
+		name      string
+		routes    []*networking.HTTPRoute
+		want      string
+		wantRoute string
+		wantErr   string
+	}{
+		{
+			name: "chain",
+			routes: []*networking.HTTPRoute{
+				{Match: exact("/home"), Redirect: &networking.HTTPRedirect{Uri: "/"}},
+				{Match: exact("/"), Redirect: &networking.HTTPRedirect{Uri: "/index.html", Scheme: "https"}},
+				{Name: "index", Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "web"}}}},
+			},
+			want:      "http://example.com/home -> http://example.com/ -> https://example.com/index.html (2 redirects)",
+			wantRoute: "index",
+		},
+		{
+			name: "loop",
+			routes: []*networking.HTTPRoute{
+				{Match: exact("/home"), Redirect: &networking.HTTPRedirect{Uri: "/"}},
+				{Match: exact("/"), Redirect: &networking.HTTPRedirect{Uri: "/home"}},
+			},
+			wantErr: "redirect loop: http://example.com/home -> http://example.com/ -> http://example.com/home (2 redirects)",
+		},
+		{
+			name: "no redirect",
+			routes: []*networking.HTTPRoute{
+				{Name: "home", Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "web"}}}},
+			},
+			want:      "http://example.com/home (0 redirects)",
+			wantRoute: "home",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := requestRouter{virtualServices: []*v1.VirtualService{{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "web"},
+				Spec:       networking.VirtualService{Hosts: []string{"example.com"}, Http: tt.routes},
+			}}}
+			input := parser.Input{Authority: "example.com", Method: "GET", URI: "/home"}
+			route, normalized, err := router.route(input)
+			require.NoError(t, err)
+			chain, route, _, err := followRedirects(router, normalized, route)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, chain.String())
+			require.Equal(t, tt.wantRoute, route.Name)
+		})
+	}
+}
+
+func TestRedirectedRequest(t *testing.T) {
+	input := parser.Input{Authority: "example.com", Method: "POST", URI: "/home", Query: map[string]string{"lang": "en"}}
+	tests := []struct {
+		name     string
+		location string
+		code     uint32
+		want     parser.Input
+	}{
+		{
+			name:     "moved permanently",
+			location: "https://www.example.com:8443/?from=home",
+			code:     301,
+			want:     parser.Input{Authority: "www.example.com", Method: "GET", URI: "/", Query: map[string]string{"from": "home"}, Scheme: "https", Port: 8443},
+		},
+		{
+			name:     "permanent redirect keeps the method",
+			location: "http://example.com/index",
+			code:     308,
+			want:     parser.Input{Authority: "example.com", Method: "POST", URI: "/index", Scheme: "http"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := redirectedRequest(input, tt.location, tt.code)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
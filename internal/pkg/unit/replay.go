@@ -0,0 +1,132 @@
+package unit
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+)
+
+// Replay routes the requests read from Envoy access logs through the virtualservices. It reports the
+// distribution of the routes taken, the requests that would now get no route (404) and the requests routed
+// to a destination other than the upstream cluster logged at the time. Paths are normalized as in Run.
+func Replay(logfiles, configfiles []string, headers []string, opts ...optionFunc) ([]string, []string, error) {
+	var summary, details []string
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	entries, err := parser.ParseAccessLogs(logfiles, headers)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing access logs failed: %w", err)
+	}
+	virtualServices, err := parser.ParseVirtualServices(configfiles)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing virtualservices failed: %w", err)
+	}
+
+	distribution := map[string]int{}
+	var noRouteCount, changedCount int
+	for _, entry := range entries {
+		normalized := entry.Input
+		normalized.URI = NormalizePath(entry.Input.URI, o.pathNormalization)
+		route, _, err := resolveRoute(normalized, virtualServices)
+		if err != nil {
+			details = append(details, fmt.Sprintf("FAIL input:[%v]", entry.Input))
+			return summary, details, fmt.Errorf("error getting destinations: %v", err)
+		}
+		description := describeRoute(route)
+		distribution[description]++
+
+		switch {
+		case !hasRoute(route):
+			noRouteCount++
+			details = append(details, fmt.Sprintf("NOROUTE input:[%v] logged: %d %s", entry.Input, entry.ResponseCode, entry.UpstreamCluster))
+		case !routesToCluster(route, entry.UpstreamCluster):
+			changedCount++
+			details = append(details, fmt.Sprintf("CHANGED input:[%v] logged: %s, now: %s", entry.Input, entry.UpstreamCluster, description))
+		}
+	}
+
+	summary = append(summary, "Replay summary:")
+	summary = append(summary, fmt.Sprintf(" - %d logfiles, %d configfiles", len(logfiles), len(configfiles)))
+	summary = append(summary, fmt.Sprintf(" - %d requests replayed, %d would get no route, %d routed away from the logged upstream cluster", len(entries), noRouteCount, changedCount))
+	summary = append(summary, "Route distribution:")
+	routes := slices.SortedFunc(maps.Keys(distribution), func(a, b string) int {
+		return cmp.Or(distribution[b]-distribution[a], strings.Compare(a, b))
+	})
+	for _, description := range routes {
+		summary = append(summary, fmt.Sprintf(" - %d %s", distribution[description], description))
+	}
+	return summary, details, nil
+}
+
+// hasRoute returns false when no rule matched, i.e. Envoy would reply with a 404.
+func hasRoute(route *networking.HTTPRoute) bool {
+	return len(route.Route) > 0 || route.Redirect != nil || route.DirectResponse != nil || route.Delegate != nil
+}
+
+// describeRoute returns a short, human readable, description of where a route sends requests.
+func describeRoute(route *networking.HTTPRoute) string {
+	switch {
+	case route.Redirect != nil:
+		return fmt.Sprintf("redirect %v", route.Redirect)
+	case route.DirectResponse != nil:
+		return fmt.Sprintf("direct response %d", route.DirectResponse.Status)
+	case len(route.Route) > 0:
+		var destinations []string
+		for _, destination := range route.Route {
+			destinations = append(destinations, describeDestination(destination))
+		}
+		return strings.Join(destinations, ", ")
+	case route.Delegate != nil:
+		return fmt.Sprintf("delegate %s/%s", route.Delegate.Namespace, route.Delegate.Name)
+	}
+	return "no route"
+}
+
+func describeDestination(destination *networking.HTTPRouteDestination) string {
+	out := destination.GetDestination().GetHost()
+	if port := destination.GetDestination().GetPort().GetNumber(); port != 0 {
+		out += ":" + strconv.Itoa(int(port))
+	}
+	if subset := destination.GetDestination().GetSubset(); subset != "" {
+		out += " subset " + subset
+	}
+	if weight := destination.GetWeight(); weight != 0 {
+		out += fmt.Sprintf(" (%d%%)", weight)
+	}
+	return out
+}
+
+// routesToCluster returns true when one of the route destinations is the given Envoy outbound cluster, named
+// "outbound|<port>|<subset>|<host>". Clusters that are not outbound, e.g. PassthroughCluster, cannot be
+// compared and are always considered as matching.
+func routesToCluster(route *networking.HTTPRoute, cluster string) bool {
+	parts := strings.Split(cluster, "|")
+	if len(parts) != 4 || parts[0] != "outbound" {
+		return true
+	}
+	port, subset, host := parts[1], parts[2], parts[3]
+	for _, destination := range route.Route {
+		d := destination.GetDestination()
+		// Short names are expanded by Istio with the namespace and domain, so they only need to prefix the host.
+		if d.GetHost() != host && !strings.HasPrefix(host, d.GetHost()+".") {
+			continue
+		}
+		if d.GetSubset() != subset {
+			continue
+		}
+		if d.GetPort() != nil && strconv.Itoa(int(d.GetPort().GetNumber())) != port {
+			continue
+		}
+		return true
+	}
+	return false
+}
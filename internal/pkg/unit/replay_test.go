@@ -0,0 +1,112 @@
+package unit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	meshconfig "istio.io/api/mesh/v1alpha1"
+	networking "istio.io/api/networking/v1"
+)
+
+func TestReplay(t *testing.T) {
+	logfiles := []string{"testdata/replay/access.log"}
+	configfiles := []string{"../../../examples/virtualservice.yml"}
+	summary, details, err := Replay(logfiles, configfiles, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"CHANGED input:[{example.com GET /about map[] map[]}] logged: outbound|80||cms.cms.svc.cluster.local, now: monolith.monolith.svc.cluster.local",
+		"NOROUTE input:[{unknown.example.com GET / map[] map[]}] logged: 200 outbound|80||monolith.monolith.svc.cluster.local",
+	}, details)
+	require.Equal(t, []string{
+		"Replay summary:",
+		" - 1 logfiles, 1 configfiles",
+		" - 4 requests replayed, 1 would get no route, 1 routed away from the logged upstream cluster",
+		"Route distribution:",
+		" - 1 monolith.monolith.svc.cluster.local",
+		" - 1 no route",
+		" - 1 partner.partner.svc.cluster.local:8000",
+		" - 1 users.users.svc.cluster.local:80",
+	}, summary)
+}
+
+func TestReplayPathNormalization(t *testing.T) {
+	logfile := filepath.Join(t.TempDir(), "access.log")
+	line := `{"authority":"example.com","method":"GET","path":"/users/../partners","upstream_cluster":"outbound|8000||partner.partner.svc.cluster.local"}`
+	require.NoError(t, os.WriteFile(logfile, []byte(line+"\n"), 0o600))
+	configfiles := []string{"../../../examples/virtualservice.yml"}
+
+	_, details, err := Replay([]string{logfile}, configfiles, nil)
+	require.NoError(t, err)
+	require.Empty(t, details)
+
+	_, details, err = Replay([]string{logfile}, configfiles, nil, WithPathNormalization(meshconfig.MeshConfig_ProxyPathNormalization_NONE))
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"CHANGED input:[{example.com GET /users/../partners map[] map[]}] logged: outbound|8000||partner.partner.svc.cluster.local, now: users.users.svc.cluster.local:80",
+	}, details)
+}
+
+func TestRoutesToCluster(t *testing.T) {
+	route := &networking.HTTPRoute{
+		Route: []*networking.HTTPRouteDestination{{
+			Destination: &networking.Destination{Host: "reviews", Subset: "v1"},
+			Weight:      90,
+		}, {
+			Destination: &networking.Destination{Host: "reviews", Subset: "v2", Port: &networking.PortSelector{Number: 9080}},
+			Weight:      10,
+		}},
+	}
+	for _, tt := range []struct {
+		cluster string
+		want    bool
+	}{
+		{cluster: "outbound|9080|v1|reviews.default.svc.cluster.local", want: true},
+		{cluster: "outbound|9080|v2|reviews.default.svc.cluster.local", want: true},
+		{cluster: "outbound|8080|v2|reviews.default.svc.cluster.local", want: false},
+		{cluster: "outbound|9080|v3|reviews.default.svc.cluster.local", want: false},
+		{cluster: "outbound|9080|v1|ratings.default.svc.cluster.local", want: false},
+		{cluster: "PassthroughCluster", want: true},
+	} {
+		t.Run(tt.cluster, func(t *testing.T) {
+			require.Equal(t, tt.want, routesToCluster(route, tt.cluster))
+		})
+	}
+}
+
+func TestDescribeRoute(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		route *networking.HTTPRoute
+		want  string
+	}{
+		{
+			name: "destinations",
+			route: &networking.HTTPRoute{Route: []*networking.HTTPRouteDestination{{
+				Destination: &networking.Destination{Host: "reviews", Subset: "v1", Port: &networking.PortSelector{Number: 9080}},
+				Weight:      100,
+			}}},
+			want: "reviews:9080 subset v1 (100%)",
+		},
+		{
+			name:  "direct response",
+			route: &networking.HTTPRoute{DirectResponse: &networking.HTTPDirectResponse{Status: 503}},
+			want:  "direct response 503",
+		},
+		{
+			name:  "delegate",
+			route: &networking.HTTPRoute{Delegate: &networking.Delegate{Name: "reviews", Namespace: "default"}},
+			want:  "delegate default/reviews",
+		},
+		{
+			name:  "no route",
+			route: &networking.HTTPRoute{},
+			want:  "no route",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, describeRoute(tt.route))
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package unit
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+)
+
+// response is the outcome of a request from the client point of view.
+type response struct {
+	Status uint32
+	// Destinations are the hosts the request may be routed to, when it is.
+	Destinations []string
+	// Location is the location the request is redirected to, when it is.
+	Location string
+}
+
+func (r response) String() string {
+	switch {
+	case r.Location != "":
+		return fmt.Sprintf("%d to %s", r.Status, r.Location)
+	case len(r.Destinations) > 0:
+		return fmt.Sprintf("%d via %s", r.Status, strings.Join(r.Destinations, ", "))
+	}
+	return strconv.Itoa(int(r.Status))
+}
+
+// resolveResponse returns the response a client gets for the request matching the route: 404 when no rule
+// matched, the abort status of faults aborting all requests, the redirect, the direct response or, for routes
+// with destinations, a 200 from one of them.
+func resolveResponse(input parser.Input, route *networking.HTTPRoute) response {
+	if !hasRoute(route) {
+		return response{Status: http.StatusNotFound}
+	}
+	// The fault filter runs before the router, whatever the route does.
+	if abort := route.GetFault().GetAbort(); abort.GetHttpStatus() != 0 && abort.GetPercentage().GetValue() >= 100 {
+		return response{Status: uint32(abort.GetHttpStatus())}
+	}
+	if route.Redirect != nil {
+		return response{
+			Status:   cmp.Or(route.Redirect.RedirectCode, http.StatusMovedPermanently),
+			Location: redirectLocation(input, route),
+		}
+	}
+	if route.DirectResponse != nil {
+		return response{Status: route.DirectResponse.Status}
+	}
+	out := response{Status: http.StatusOK}
+	for _, destination := range route.Route {
+		// Destinations without weight get no traffic, unless they are the only one.
+		if destination.Weight == 0 && len(route.Route) > 1 {
+			continue
+		}
+		out.Destinations = append(out.Destinations, destination.GetDestination().GetHost())
+	}
+	return out
+}
+
+// redirectLocation returns the location header of the redirect. The scheme of the request is taken from the
+// x-forwarded-proto header or the request scheme, http by default, and the query parameters are kept unless the redirect sets its own.
+func redirectLocation(input parser.Input, route *networking.HTTPRoute) string {
+	redirect := route.Redirect
+	scheme := cmp.Or(redirect.Scheme, header(input, "x-forwarded-proto"), input.Scheme, "http")
+	location := url.URL{
+		Scheme: scheme,
+		Host:   cmp.Or(redirect.Authority, input.Authority),
+	}
+	if port := redirect.GetPort(); port != 0 && !(scheme == "http" && port == 80) && !(scheme == "https" && port == 443) {
+		host, _, _ := strings.Cut(location.Host, ":")
+		location.Host = host + ":" + strconv.Itoa(int(port))
+	}
+	path := cmp.Or(redirect.Uri, input.URI)
+	if replacement, ok := strings.CutPrefix(redirect.Uri, parser.PrefixReplacement); ok {
+		path = replacePrefix(input.URI, matchedPrefix(input, route), replacement)
+	}
+	path, rawQuery, hasQuery := strings.Cut(path, "?")
+	location.Path = path
+	if hasQuery {
+		location.RawQuery = rawQuery
+	} else if len(input.Query) > 0 {
+		query := url.Values{}
+		for name, value := range input.Query {
+			query.Set(name, value)
+		}
+		location.RawQuery = query.Encode()
+	}
+	return location.String()
+}
+
+// matchedPrefix returns the uri prefix of the first match of the route the request matches.
+func matchedPrefix(input parser.Input, route *networking.HTTPRoute) string {
+	for _, match := range route.Match {
+		if ok, err := matchRequest(input, match); err == nil && ok {
+			return match.GetUri().GetPrefix()
+		}
+	}
+	return ""
+}
+
+// replacePrefix replaces the prefix of the path, e.g. "/foo" in "/foo/bar" by "/baz" gives "/baz/bar", as
+// Gateway API prefix redirects do.
+func replacePrefix(path, prefix, replacement string) string {
+	rest := strings.TrimPrefix(path, strings.TrimSuffix(prefix, "/"))
+	return cmp.Or(strings.TrimSuffix(replacement, "/")+rest, "/")
+}
+
+// matchResponse returns true when the response has the expected status and, when set, destination and location.
+// Short destination hosts are expanded in the namespace of the route.
+func matchResponse(got response, want *parser.ExpectResponse, namespace string) bool {
+	if want.Status != 0 && got.Status != want.Status {
+		return false
+	}
+	if want.Location != "" && got.Location != want.Location {
+		return false
+	}
+	if want.Destination != "" {
+		for _, host := range got.Destinations {
+			if matchDestinationHost(host, want.Destination, namespace) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// matchDestinationHost returns true when the destination host is the expected one. Short names of both are
+// expanded, as Istio does, with the namespace of the route and the cluster domain.
+func matchDestinationHost(got, want, namespace string) bool {
+	return parser.FQDN(got, namespace) == parser.FQDN(want, namespace)
+}
@@ -0,0 +1,140 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+)
+
+func TestResolveResponse(t *testing.T) {
+	input := parser.Input{Authority: "www.example.com", Method: "GET", URI: "/home", Query: map[string]string{"lang": "en"}}
+	tests := []struct {
+		name  string
+		input parser.Input
+		route *networking.HTTPRoute
+		want  response
+	}{
+		{
+			name:  "no route",
+			input: input,
+			route: &networking.HTTPRoute{},
+			want:  response{Status: 404},
+		},
+		{
+			name:  "redirect",
+			input: input,
+			route: &networking.HTTPRoute{Redirect: &networking.HTTPRedirect{Uri: "/"}},
+			want:  response{Status: 301, Location: "http://www.example.com/?lang=en"},
+		},
+		{
+			name: "redirect with forwarded proto, code and port",
+			input: parser.Input{Authority: "www.example.com", Method: "GET", URI: "/home", Headers: map[string]string{
+				"x-forwarded-proto": "https",
+			}},
+			route: &networking.HTTPRoute{Redirect: &networking.HTTPRedirect{
+				Authority:    "example.com",
+				RedirectCode: 308,
+				RedirectPort: &networking.HTTPRedirect_Port{Port: 8443},
+			}},
+			want: response{Status: 308, Location: "https://example.com:8443/home"},
+		},
+		{
+			name:  "redirect with its own query",
+			input: input,
+			route: &networking.HTTPRoute{Redirect: &networking.HTTPRedirect{Uri: "/?from=home", Scheme: "https"}},
+			want:  response{Status: 301, Location: "https://www.example.com/?from=home"},
+		},
+		{
+			name:  "redirect replacing the matched prefix",
+			input: parser.Input{Authority: "www.example.com", Method: "GET", URI: "/trips/berlin"},
+			route: &networking.HTTPRoute{
+				Match:    []*networking.HTTPMatchRequest{{Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/trips"}}}},
+				Redirect: &networking.HTTPRedirect{Uri: parser.PrefixReplacement + "/tours", RedirectCode: 302},
+			},
+			want: response{Status: 302, Location: "http://www.example.com/tours/berlin"},
+		},
+		{
+			name:  "fault aborting all requests",
+			input: input,
+			route: &networking.HTTPRoute{
+				Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "home"}}},
+				Fault: &networking.HTTPFaultInjection{Abort: &networking.HTTPFaultInjection_Abort{
+					ErrorType:  &networking.HTTPFaultInjection_Abort_HttpStatus{HttpStatus: 403},
+					Percentage: &networking.Percent{Value: 100},
+				}},
+			},
+			want: response{Status: 403},
+		},
+		{
+			name:  "fault aborting some requests",
+			input: input,
+			route: &networking.HTTPRoute{
+				Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: "home"}}},
+				Fault: &networking.HTTPFaultInjection{Abort: &networking.HTTPFaultInjection_Abort{
+					ErrorType:  &networking.HTTPFaultInjection_Abort_HttpStatus{HttpStatus: 503},
+					Percentage: &networking.Percent{Value: 10},
+				}},
+			},
+			want: response{Status: 200, Destinations: []string{"home"}},
+		},
+		{
+			name:  "direct response",
+			input: input,
+			route: &networking.HTTPRoute{DirectResponse: &networking.HTTPDirectResponse{Status: 503}},
+			want:  response{Status: 503},
+		},
+		{
+			name:  "weighted destinations",
+			input: input,
+			route: &networking.HTTPRoute{Route: []*networking.HTTPRouteDestination{
+				{Destination: &networking.Destination{Host: "home"}, Weight: 90},
+				{Destination: &networking.Destination{Host: "home-canary"}, Weight: 10},
+				{Destination: &networking.Destination{Host: "home-next"}},
+			}},
+			want: response{Status: 200, Destinations: []string{"home", "home-canary"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, resolveResponse(tt.input, tt.route))
+		})
+	}
+}
+
+func TestMatchResponse(t *testing.T) {
+	got := response{Status: 200, Destinations: []string{"home", "home-canary.example.svc.cluster.local"}}
+	namespace := "example"
+	require.True(t, matchResponse(got, &parser.ExpectResponse{Status: 200}, namespace))
+	require.True(t, matchResponse(got, &parser.ExpectResponse{Destination: "home.example.svc.cluster.local"}, namespace))
+	require.True(t, matchResponse(got, &parser.ExpectResponse{Status: 200, Destination: "home-canary.example.svc.cluster.local"}, namespace))
+	require.True(t, matchResponse(got, &parser.ExpectResponse{Destination: "home-canary"}, namespace))
+	require.False(t, matchResponse(got, &parser.ExpectResponse{Destination: "home.other.svc.cluster.local"}, namespace))
+	require.False(t, matchResponse(got, &parser.ExpectResponse{Destination: "home-canary"}, "other"))
+	require.False(t, matchResponse(got, &parser.ExpectResponse{Status: 404}, namespace))
+	require.False(t, matchResponse(got, &parser.ExpectResponse{Location: "http://www.example.com/"}, namespace))
+}
+
+func TestMatchDestinationHost(t *testing.T) {
+	require.True(t, matchDestinationHost("users", "users.users.svc.cluster.local", "users"))
+	require.True(t, matchDestinationHost("users.users.svc.cluster.local", "users", "users"))
+	require.True(t, matchDestinationHost("users", "users", "users"))
+	require.False(t, matchDestinationHost("users.users.svc.cluster.local", "users", "web"))
+	require.False(t, matchDestinationHost("users.example.com", "users", "users"))
+}
+
+func TestReplacePrefix(t *testing.T) {
+	tests := []struct {
+		path, prefix, replacement, want string
+	}{
+		{path: "/trips/berlin", prefix: "/trips", replacement: "/tours", want: "/tours/berlin"},
+		{path: "/trips", prefix: "/trips/", replacement: "/tours/", want: "/tours"},
+		{path: "/trips/berlin", prefix: "/trips", replacement: "/", want: "/berlin"},
+		{path: "/trips", prefix: "/trips", replacement: "/", want: "/"},
+		{path: "/berlin", prefix: "/", replacement: "/tours", want: "/tours/berlin"},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, replacePrefix(tt.path, tt.prefix, tt.replacement), tt.path)
+	}
+}
@@ -0,0 +1,192 @@
+package unit
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+)
+
+// SampleInputs generates inputs exercising every http rule of the given virtualservices. Each match block
+// yields one request per host, crafted from the values its StringMatches accept. Delegate virtualservices
+// have no hosts of their own, so they are sampled with the hosts of the virtualservices delegating to them.
+func SampleInputs(virtualServices []*v1.VirtualService) []parser.Input {
+	var out []parser.Input
+	for _, vs := range virtualServices {
+		hosts := vs.Spec.Hosts
+		if len(hosts) == 0 {
+			hosts = delegatingHosts(vs, virtualServices)
+		}
+		for _, httpRoute := range vs.Spec.Http {
+			if len(httpRoute.Match) == 0 {
+				for _, host := range hosts {
+					out = append(out, parser.Input{Authority: host, Method: "GET", URI: "/"})
+				}
+				continue
+			}
+			for _, matchBlock := range httpRoute.Match {
+				out = append(out, sampleMatch(matchBlock, hosts)...)
+			}
+		}
+	}
+	return out
+}
+
+// delegatingHosts returns the hosts of all virtualservices delegating at least one route to vs.
+func delegatingHosts(vs *v1.VirtualService, virtualServices []*v1.VirtualService) []string {
+	var hosts []string
+	for _, root := range virtualServices {
+		for _, httpRoute := range root.Spec.Http {
+			delegate := httpRoute.Delegate
+			if delegate == nil || delegate.Name != vs.Name {
+				continue
+			}
+			if delegate.Namespace != "" && delegate.Namespace != vs.Namespace {
+				continue
+			}
+			for _, host := range root.Spec.Hosts {
+				if !slices.Contains(hosts, host) {
+					hosts = append(hosts, host)
+				}
+			}
+		}
+	}
+	return hosts
+}
+
+// sampleMatch crafts the inputs matching a HTTPMatchRequest block. It returns no input when a value
+// accepted by one of the StringMatches cannot be generated.
+func sampleMatch(matchBlock *networking.HTTPMatchRequest, hosts []string) []parser.Input {
+	uri, ok := sampleStringMatch(matchBlock.Uri, "/")
+	if !ok {
+		return nil
+	}
+	method, ok := sampleStringMatch(matchBlock.Method, "GET")
+	if !ok {
+		return nil
+	}
+	var headers map[string]string
+	for name, sm := range matchBlock.Headers {
+		value, ok := sampleStringMatch(sm, "sample")
+		if !ok {
+			return nil
+		}
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers[name] = value
+	}
+	authorities := hosts
+	if matchBlock.Authority != nil {
+		authority, ok := sampleStringMatch(matchBlock.Authority, "")
+		if !ok {
+			return nil
+		}
+		authorities = []string{authority}
+	}
+
+	var out []parser.Input
+	for _, authority := range authorities {
+		out = append(out, parser.Input{Authority: authority, Method: method, URI: uri, Headers: headers})
+	}
+	return out
+}
+
+// sampleStringMatch returns a string accepted by the StringMatch, or fallback when it accepts anything.
+func sampleStringMatch(sm *networking.StringMatch, fallback string) (string, bool) {
+	switch {
+	case sm.GetExact() != "":
+		return sm.GetExact(), true
+	case sm.GetPrefix() != "":
+		return sm.GetPrefix(), true
+	case sm.GetRegex() != "":
+		s, err := sampleRegex(sm.GetRegex())
+		return s, err == nil
+	}
+	return fallback, true
+}
+
+// sampleRegex returns a string fully matching the regex, following Envoy semantics where the rule will not
+// match if only a subsequence of the string matches the regex.
+func sampleRegex(expr string) (string, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", fmt.Errorf("could not parse regex %s: %v", expr, err)
+	}
+	var b strings.Builder
+	if !writeSample(&b, re.Simplify()) {
+		return "", fmt.Errorf("could not generate a sample for regex %s", expr)
+	}
+	full, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return "", fmt.Errorf("could not compile regex %s: %v", expr, err)
+	}
+	if !full.MatchString(b.String()) {
+		return "", fmt.Errorf("could not generate a sample for regex %s", expr)
+	}
+	return b.String(), nil
+}
+
+// writeSample writes the shortest string matched by re, taking the first branch of every alternation.
+func writeSample(b *strings.Builder, re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		r, ok := sampleRune(re.Rune)
+		if !ok {
+			return false
+		}
+		b.WriteRune(r)
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune('a')
+	case syntax.OpCapture, syntax.OpPlus:
+		return writeSample(b, re.Sub[0])
+	case syntax.OpRepeat:
+		for range re.Min {
+			if !writeSample(b, re.Sub[0]) {
+				return false
+			}
+		}
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			if !writeSample(b, sub) {
+				return false
+			}
+		}
+	case syntax.OpAlternate:
+		return writeSample(b, re.Sub[0])
+	case syntax.OpNoMatch:
+		return false
+	}
+	// OpStar, OpQuest, OpEmptyMatch and the anchors match the empty string.
+	return true
+}
+
+// sampleRune picks a printable rune from the class ranges, preferring the ones readable in a URL.
+func sampleRune(ranges []rune) (rune, bool) {
+	inClass := func(r rune) bool {
+		for i := 0; i+1 < len(ranges); i += 2 {
+			if ranges[i] <= r && r <= ranges[i+1] {
+				return true
+			}
+		}
+		return false
+	}
+	for _, r := range "aA0-_/" {
+		if inClass(r) {
+			return r, true
+		}
+	}
+	for r := rune('!'); r <= '~'; r++ {
+		if inClass(r) {
+			return r, true
+		}
+	}
+	return 0, false
+}
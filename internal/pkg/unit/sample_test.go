@@ -0,0 +1,79 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSampleInputs(t *testing.T) {
+	virtualServices := []*v1.VirtualService{{
+		ObjectMeta: metav1.ObjectMeta{Name: "root", Namespace: "example"},
+		Spec: networking.VirtualService{
+			Hosts: []string{"www.example.com"},
+			Http: []*networking.HTTPRoute{{
+				Match: []*networking.HTTPMatchRequest{{
+					Uri:    &networking.StringMatch{MatchType: &networking.StringMatch_Regex{Regex: "/users(/.*)?"}},
+					Method: &networking.StringMatch{MatchType: &networking.StringMatch_Regex{Regex: "(GET|OPTIONS)"}},
+					Headers: map[string]*networking.StringMatch{
+						"x-user-type": {MatchType: &networking.StringMatch_Exact{Exact: "qa"}},
+					},
+				}},
+			}, {
+				Match: []*networking.HTTPMatchRequest{{
+					Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Prefix{Prefix: "/delegated"}},
+				}},
+				Delegate: &networking.Delegate{Name: "delegated"},
+			}, {
+				Route: []*networking.HTTPRouteDestination{{
+					Destination: &networking.Destination{Host: "fallback.fallback.svc.cluster.local"},
+				}},
+			}},
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "delegated", Namespace: "example"},
+		Spec: networking.VirtualService{
+			Http: []*networking.HTTPRoute{{
+				Match: []*networking.HTTPMatchRequest{{
+					Uri: &networking.StringMatch{MatchType: &networking.StringMatch_Exact{Exact: "/delegated/v1"}},
+				}},
+			}},
+		},
+	}}
+
+	want := []parser.Input{
+		{Authority: "www.example.com", Method: "GET", URI: "/users", Headers: map[string]string{"x-user-type": "qa"}},
+		{Authority: "www.example.com", Method: "GET", URI: "/delegated"},
+		{Authority: "www.example.com", Method: "GET", URI: "/"},
+		{Authority: "www.example.com", Method: "GET", URI: "/delegated/v1"},
+	}
+	require.Equal(t, want, SampleInputs(virtualServices))
+}
+
+func TestSampleRegex(t *testing.T) {
+	for _, tt := range []struct {
+		regex   string
+		want    string
+		wantErr bool
+	}{
+		{regex: "/users(/.*)?", want: "/users"},
+		{regex: "/api/v[0-9]+/items", want: "/api/v0/items"},
+		{regex: "/[^/]+/details", want: "/a/details"},
+		{regex: "(foo|bar){2}", want: "foofoo"},
+		{regex: "/(", wantErr: true},
+	} {
+		t.Run(tt.regex, func(t *testing.T) {
+			got, err := sampleRegex(tt.regex)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
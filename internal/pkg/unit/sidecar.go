@@ -0,0 +1,113 @@
+package unit
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/authz"
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+)
+
+// findSidecar returns the sidecar resource applying to the workload, or nil when none does. As in istio, a
+// sidecar of the workload namespace selecting its labels wins over the one of its namespace without selector,
+// which wins over the one of the root namespace, authz.RootNamespace.
+func findSidecar(sidecars []*v1.Sidecar, source *parser.Source) *v1.Sidecar {
+	var namespaceDefault, rootDefault *v1.Sidecar
+	for _, sidecar := range sidecars {
+		selector := sidecar.Spec.GetWorkloadSelector().GetLabels()
+		switch {
+		case sidecar.Namespace == source.Namespace && len(selector) > 0:
+			if selects(selector, source.Labels) {
+				return sidecar
+			}
+		case sidecar.Namespace == source.Namespace:
+			namespaceDefault = sidecar
+		case sidecar.Namespace == authz.RootNamespace && len(selector) == 0:
+			rootDefault = sidecar
+		}
+	}
+	if namespaceDefault != nil {
+		return namespaceDefault
+	}
+	return rootDefault
+}
+
+// selects returns true when the labels have all the labels of the selector.
+func selects(selector, labels map[string]string) bool {
+	for name, value := range selector {
+		if got, ok := labels[name]; !ok || got != value {
+			return false
+		}
+	}
+	return true
+}
+
+// visibleVirtualServices returns the virtualservices imported by the egress listeners of the sidecar matching
+// the port of the request. Egress hosts are namespace/host, where the namespace may be "*" for any, "." for the
+// one of the sidecar and "~" for none. A nil sidecar, or one without egress listeners, imports everything, as
+// Istio defaults the egress hosts to "*/*".
+func visibleVirtualServices(virtualServices []*v1.VirtualService, sidecar *v1.Sidecar, input parser.Input) []*v1.VirtualService {
+	if sidecar == nil || len(sidecar.Spec.Egress) == 0 {
+		return virtualServices
+	}
+	var egressHosts []string
+	for _, egress := range sidecar.Spec.Egress {
+		if port := egress.GetPort().GetNumber(); port != 0 && input.Port != 0 && port != input.Port {
+			continue
+		}
+		egressHosts = append(egressHosts, egress.Hosts...)
+	}
+	var out []*v1.VirtualService
+	for _, vs := range virtualServices {
+		if slices.ContainsFunc(egressHosts, func(egressHost string) bool { return imports(egressHost, sidecar.Namespace, vs) }) {
+			out = append(out, vs)
+		}
+	}
+	return out
+}
+
+// hiddenHostRoute returns the route of a request whose host only has virtualservices the sidecar hides, nil
+// otherwise. Envoy has then no route for the host and sends the request to the PassthroughCluster, that is to
+// the host as requested, or to the BlackHoleCluster, answering a 502, when the sidecar only allows the
+// services of the registry.
+func hiddenHostRoute(virtualServices []*v1.VirtualService, sidecar *v1.Sidecar, input parser.Input) *networking.HTTPRoute {
+	if sidecar == nil || len(hostVirtualServices(virtualServices, input.Authority)) == 0 {
+		return nil
+	}
+	if len(hostVirtualServices(visibleVirtualServices(virtualServices, sidecar, input), input.Authority)) > 0 {
+		return nil
+	}
+	// Sidecars without outbound traffic policy follow the one of the mesh, ALLOW_ANY by default.
+	if policy := sidecar.Spec.GetOutboundTrafficPolicy(); policy != nil && policy.Mode == networking.OutboundTrafficPolicy_REGISTRY_ONLY {
+		return &networking.HTTPRoute{Name: "BlackHoleCluster", DirectResponse: &networking.HTTPDirectResponse{Status: http.StatusBadGateway}}
+	}
+	return &networking.HTTPRoute{
+		Name:  "PassthroughCluster",
+		Route: []*networking.HTTPRouteDestination{{Destination: &networking.Destination{Host: input.Authority}}},
+	}
+}
+
+// imports returns true when the egress host imports the virtualservice, that is when its namespace matches and
+// it matches one of the virtualservice hosts.
+func imports(egressHost, sidecarNamespace string, vs *v1.VirtualService) bool {
+	namespace, host, ok := strings.Cut(egressHost, "/")
+	if !ok {
+		namespace, host = "*", egressHost
+	}
+	switch namespace {
+	case "~":
+		return false
+	case ".":
+		namespace = sidecarNamespace
+	}
+	if namespace != "*" && namespace != vs.Namespace {
+		return false
+	}
+	return slices.ContainsFunc(vs.Spec.Hosts, func(vsHost string) bool {
+		vsHost = parser.FQDN(vsHost, vs.Namespace)
+		return parser.MatchHost(vsHost, host) || parser.MatchHost(host, vsHost)
+	})
+}
@@ -0,0 +1,135 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestFindSidecar(t *testing.T) {
+	sidecar := func(namespace, name string, selector map[string]string) *v1.Sidecar {
+		out := &v1.Sidecar{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace}}
+		if selector != nil {
+			out.Spec.WorkloadSelector = &networking.WorkloadSelector{Labels: selector}
+		}
+		return out
+	}
+	sidecars := []*v1.Sidecar{
+		sidecar("istio-system", "root", nil),
+		sidecar("shop", "checkout", map[string]string{"app": "checkout"}),
+		sidecar("shop", "default", nil),
+	}
+	tests := []struct {
+		name   string
+		source *parser.Source
+		want   string
+	}{
+		{name: "workload selector", source: &parser.Source{Namespace: "shop", Labels: map[string]string{"app": "checkout", "version": "v1"}}, want: "checkout"},
+		{name: "namespace default", source: &parser.Source{Namespace: "shop", Labels: map[string]string{"app": "web"}}, want: "default"},
+		{name: "root namespace", source: &parser.Source{Namespace: "search"}, want: "root"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, findSidecar(sidecars, tt.source).Name)
+		})
+	}
+	require.Nil(t, findSidecar(sidecars[1:], &parser.Source{Namespace: "search"}))
+}
+
+func TestVisibleVirtualServices(t *testing.T) {
+	virtualService := func(namespace string, hosts ...string) *v1.VirtualService {
+		return &v1.VirtualService{
+			ObjectMeta: metav1.ObjectMeta{Name: hosts[0], Namespace: namespace},
+			Spec:       networking.VirtualService{Hosts: hosts},
+		}
+	}
+	local := virtualService("shop", "cart")
+	payments := virtualService("payments", "payments.payments.svc.cluster.local")
+	external := virtualService("egress", "api.example.com")
+	virtualServices := []*v1.VirtualService{local, payments, external}
+	tests := []struct {
+		name   string
+		egress []*networking.IstioEgressListener
+		input  parser.Input
+		want   []*v1.VirtualService
+	}{
+		{
+			name:   "own namespace",
+			egress: []*networking.IstioEgressListener{{Hosts: []string{"./*"}}},
+			want:   []*v1.VirtualService{local},
+		},
+		{
+			name:   "host in any namespace",
+			egress: []*networking.IstioEgressListener{{Hosts: []string{"*/*.example.com", "payments/payments.payments.svc.cluster.local"}}},
+			want:   []*v1.VirtualService{payments, external},
+		},
+		{
+			name:   "no namespace",
+			egress: []*networking.IstioEgressListener{{Hosts: []string{"~/*"}}},
+		},
+		{
+			name: "listener on another port",
+			egress: []*networking.IstioEgressListener{
+				{Hosts: []string{"./*"}},
+				{Port: &networking.SidecarPort{Number: 8080}, Hosts: []string{"egress/*"}},
+			},
+			input: parser.Input{Port: 80},
+			want:  []*v1.VirtualService{local},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sidecar := &v1.Sidecar{
+				ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "shop"},
+				Spec:       networking.Sidecar{Egress: tt.egress},
+			}
+			require.Equal(t, tt.want, visibleVirtualServices(virtualServices, sidecar, tt.input))
+		})
+	}
+	require.Equal(t, virtualServices, visibleVirtualServices(virtualServices, nil, parser.Input{}))
+
+	withoutEgress := &v1.Sidecar{
+		ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "shop"},
+		Spec: networking.Sidecar{
+			WorkloadSelector: &networking.WorkloadSelector{Labels: map[string]string{"app": "checkout"}},
+			Ingress: []*networking.IstioIngressListener{{
+				Port:            &networking.SidecarPort{Number: 8080, Protocol: "HTTP", Name: "http"},
+				DefaultEndpoint: "127.0.0.1:8080",
+			}},
+		},
+	}
+	require.Equal(t, virtualServices, visibleVirtualServices(virtualServices, withoutEgress, parser.Input{}))
+	require.Nil(t, hiddenHostRoute(virtualServices, withoutEgress, parser.Input{Authority: "cart"}))
+}
+
+func TestHiddenHostRoute(t *testing.T) {
+	virtualServices := []*v1.VirtualService{{
+		ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: "payments"},
+		Spec:       networking.VirtualService{Hosts: []string{"payments.payments.svc.cluster.local"}},
+	}}
+	sidecar := func(egressHost string, policy *networking.OutboundTrafficPolicy) *v1.Sidecar {
+		return &v1.Sidecar{
+			ObjectMeta: metav1.ObjectMeta{Name: "default", Namespace: "shop"},
+			Spec: networking.Sidecar{
+				Egress:                []*networking.IstioEgressListener{{Hosts: []string{egressHost}}},
+				OutboundTrafficPolicy: policy,
+			},
+		}
+	}
+	payments := parser.Input{Authority: "payments.payments.svc.cluster.local"}
+
+	require.Nil(t, hiddenHostRoute(virtualServices, nil, payments))
+	require.Nil(t, hiddenHostRoute(virtualServices, sidecar("payments/*", nil), payments))
+	require.Nil(t, hiddenHostRoute(virtualServices, sidecar("./*", nil), parser.Input{Authority: "cart"}))
+
+	route := hiddenHostRoute(virtualServices, sidecar("./*", nil), payments)
+	require.Equal(t, "200 via payments.payments.svc.cluster.local", resolveResponse(payments, route).String())
+
+	registryOnly := &networking.OutboundTrafficPolicy{Mode: networking.OutboundTrafficPolicy_REGISTRY_ONLY}
+	route = hiddenHostRoute(virtualServices, sidecar("./*", registryOnly), payments)
+	require.Equal(t, "502", resolveResponse(payments, route).String())
+}
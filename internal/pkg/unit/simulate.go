@@ -0,0 +1,109 @@
+package unit
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"google.golang.org/protobuf/proto"
+	networking "istio.io/api/networking/v1"
+)
+
+const (
+	defaultSamples   = 1000
+	defaultTolerance = 5
+)
+
+// ErrNoWeight indicates a route with several destinations, none of them with a weight.
+var ErrNoWeight = errors.New("no destination has a weight")
+
+// simulationTolerance returns the tolerance of the simulation, defaultTolerance unless set.
+func simulationTolerance(sim parser.Simulation) float64 {
+	if sim.Tolerance == nil {
+		return defaultTolerance
+	}
+	return *sim.Tolerance
+}
+
+// newRand returns the random source of a simulation, always the same for a given seed.
+func newRand(sim parser.Simulation) *rand.Rand {
+	return rand.New(rand.NewPCG(sim.Seed, sim.Seed))
+}
+
+// simulateWeights sends the simulation samples to the route destinations, picked by weight as Envoy does, and
+// returns the share, in percent, of the samples each destination got.
+func simulateWeights(destinations []*networking.HTTPRouteDestination, sim parser.Simulation) ([]float64, error) {
+	shares := make([]float64, len(destinations))
+	if len(destinations) == 0 {
+		return shares, nil
+	}
+	// A single destination gets all the traffic, whatever its weight.
+	if len(destinations) == 1 {
+		shares[0] = 100
+		return shares, nil
+	}
+	var total int32
+	for _, destination := range destinations {
+		total += destination.Weight
+	}
+	if total == 0 {
+		return nil, ErrNoWeight
+	}
+
+	samples := cmp.Or(sim.Samples, defaultSamples)
+	r := newRand(sim)
+	counts := make([]int, len(destinations))
+	for range samples {
+		pick := r.Int32N(total)
+		for i, destination := range destinations {
+			if pick < destination.Weight {
+				counts[i]++
+				break
+			}
+			pick -= destination.Weight
+		}
+	}
+	for i, count := range counts {
+		shares[i] = 100 * float64(count) / float64(samples)
+	}
+	return shares, nil
+}
+
+// matchDistribution simulates the traffic sent to the route destinations and returns whether the observed
+// shares are within the tolerance of the expected distribution, along with a description of the shares.
+func matchDistribution(route *networking.HTTPRoute, distribution *parser.Distribution) (bool, string, error) {
+	shares, err := simulateWeights(route.Route, distribution.Simulation)
+	if err != nil {
+		return false, "", err
+	}
+	tolerance := simulationTolerance(distribution.Simulation)
+
+	match := true
+	expected := make([]float64, len(route.Route))
+	for _, want := range distribution.Destinations {
+		found := false
+		for i, destination := range route.Route {
+			if proto.Equal(destination.Destination, want.Destination) {
+				expected[i] += want.Percent
+				found = true
+			}
+		}
+		// The expected destination gets no traffic at all.
+		if !found && want.Percent > tolerance {
+			match = false
+		}
+	}
+
+	var observed []string
+	for i, destination := range route.Route {
+		observed = append(observed, fmt.Sprintf("%s: %.1f%%", describeDestination(&networking.HTTPRouteDestination{Destination: destination.Destination}), shares[i]))
+		if math.Abs(shares[i]-expected[i]) > tolerance {
+			match = false
+		}
+	}
+	return match, "[" + strings.Join(observed, ", ") + "]", nil
+}
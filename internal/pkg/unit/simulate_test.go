@@ -0,0 +1,124 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	networking "istio.io/api/networking/v1"
+)
+
+func TestSimulateWeights(t *testing.T) {
+	destination := func(host string, weight int32) *networking.HTTPRouteDestination {
+		return &networking.HTTPRouteDestination{Destination: &networking.Destination{Host: host}, Weight: weight}
+	}
+	tests := []struct {
+		name         string
+		destinations []*networking.HTTPRouteDestination
+		want         []float64
+		wantErr      error
+	}{
+		{
+			name:         "single destination",
+			destinations: []*networking.HTTPRouteDestination{destination("users", 0)},
+			want:         []float64{100},
+		},
+		{
+			name:         "zero weight gets nothing",
+			destinations: []*networking.HTTPRouteDestination{destination("users", 100), destination("users-canary", 0)},
+			want:         []float64{100, 0},
+		},
+		{
+			name:         "no weight",
+			destinations: []*networking.HTTPRouteDestination{destination("users", 0), destination("users-canary", 0)},
+			wantErr:      ErrNoWeight,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := simulateWeights(tt.destinations, parser.Simulation{Seed: 42})
+			require.ErrorIs(t, err, tt.wantErr)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSimulateWeightsDeterministic(t *testing.T) {
+	destinations := []*networking.HTTPRouteDestination{
+		{Destination: &networking.Destination{Host: "users"}, Weight: 90},
+		{Destination: &networking.Destination{Host: "users-canary"}, Weight: 10},
+	}
+	sim := parser.Simulation{Samples: 10000, Seed: 7}
+	first, err := simulateWeights(destinations, sim)
+	require.NoError(t, err)
+	second, err := simulateWeights(destinations, sim)
+	require.NoError(t, err)
+	require.Equal(t, first, second)
+	require.InDelta(t, 90, first[0], 1)
+	require.InDelta(t, 10, first[1], 1)
+}
+
+func TestMatchDistribution(t *testing.T) {
+	route := &networking.HTTPRoute{Route: []*networking.HTTPRouteDestination{
+		{Destination: &networking.Destination{Host: "users"}, Weight: 90},
+		{Destination: &networking.Destination{Host: "users-canary"}, Weight: 10},
+	}}
+	tests := []struct {
+		name         string
+		distribution *parser.Distribution
+		want         bool
+	}{
+		{
+			name: "within tolerance",
+			distribution: &parser.Distribution{Destinations: []*parser.DestinationShare{
+				{Destination: &networking.Destination{Host: "users"}, Percent: 88},
+				{Destination: &networking.Destination{Host: "users-canary"}, Percent: 12},
+			}},
+			want: true,
+		},
+		{
+			name: "out of tolerance",
+			distribution: &parser.Distribution{
+				Simulation: parser.Simulation{Tolerance: new(1.0)},
+				Destinations: []*parser.DestinationShare{
+					{Destination: &networking.Destination{Host: "users"}, Percent: 80},
+					{Destination: &networking.Destination{Host: "users-canary"}, Percent: 20},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "zero tolerance",
+			distribution: &parser.Distribution{
+				Simulation: parser.Simulation{Tolerance: new(0.0)},
+				Destinations: []*parser.DestinationShare{
+					{Destination: &networking.Destination{Host: "users"}, Percent: 90},
+					{Destination: &networking.Destination{Host: "users-canary"}, Percent: 10},
+				},
+			},
+			want: false,
+		},
+		{
+			name: "unlisted destination gets traffic",
+			distribution: &parser.Distribution{Destinations: []*parser.DestinationShare{
+				{Destination: &networking.Destination{Host: "users"}, Percent: 100},
+			}},
+			want: false,
+		},
+		{
+			name: "listed destination is not routed to",
+			distribution: &parser.Distribution{Destinations: []*parser.DestinationShare{
+				{Destination: &networking.Destination{Host: "users"}, Percent: 90},
+				{Destination: &networking.Destination{Host: "users-v2"}, Percent: 10},
+			}},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := matchDistribution(route, tt.distribution)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
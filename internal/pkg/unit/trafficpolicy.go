@@ -0,0 +1,121 @@
+package unit
+
+import (
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+)
+
+// effectiveTrafficPolicy returns the traffic policy applied to the destination, declared in the given
+// namespace. As in istio, the policy of the destinationrule is overridden by its settings for the destination
+// port, then by the policy of the subset and by the subset settings for the port. Port level settings are
+// dropped from the returned policy.
+func effectiveTrafficPolicy(config *parser.Config, namespace string, destination *networking.Destination) *networking.TrafficPolicy {
+	out := &networking.TrafficPolicy{}
+	if destination == nil {
+		return out
+	}
+	dr := config.DestinationRule(destination.Host, namespace)
+	if dr == nil {
+		return out
+	}
+	port := destination.GetPort().GetNumber()
+	mergeTrafficPolicy(out, dr.Spec.TrafficPolicy, port)
+	for _, subset := range dr.Spec.Subsets {
+		if subset.Name == destination.Subset {
+			mergeTrafficPolicy(out, subset.TrafficPolicy, port)
+		}
+	}
+	return out
+}
+
+// mergeTrafficPolicy overrides the settings of dst with the ones set in src, then with the ones set for port.
+func mergeTrafficPolicy(dst, src *networking.TrafficPolicy, port uint32) {
+	if src == nil {
+		return
+	}
+	if src.LoadBalancer != nil {
+		dst.LoadBalancer = src.LoadBalancer
+	}
+	if src.ConnectionPool != nil {
+		dst.ConnectionPool = src.ConnectionPool
+	}
+	if src.OutlierDetection != nil {
+		dst.OutlierDetection = src.OutlierDetection
+	}
+	if src.Tls != nil {
+		dst.Tls = src.Tls
+	}
+	if src.Tunnel != nil {
+		dst.Tunnel = src.Tunnel
+	}
+	if src.ProxyProtocol != nil {
+		dst.ProxyProtocol = src.ProxyProtocol
+	}
+	if src.RetryBudget != nil {
+		dst.RetryBudget = src.RetryBudget
+	}
+	if port == 0 {
+		return
+	}
+	for _, settings := range src.PortLevelSettings {
+		if settings.GetPort().GetNumber() != port {
+			continue
+		}
+		if settings.LoadBalancer != nil {
+			dst.LoadBalancer = settings.LoadBalancer
+		}
+		if settings.ConnectionPool != nil {
+			dst.ConnectionPool = settings.ConnectionPool
+		}
+		if settings.OutlierDetection != nil {
+			dst.OutlierDetection = settings.OutlierDetection
+		}
+		if settings.Tls != nil {
+			dst.Tls = settings.Tls
+		}
+	}
+}
+
+// heaviestDestination returns the destination of the route getting the most traffic, the first one on a tie.
+func heaviestDestination(route *networking.HTTPRoute) *networking.Destination {
+	var out *networking.HTTPRouteDestination
+	for _, destination := range route.Route {
+		if out == nil || destination.Weight > out.Weight {
+			out = destination
+		}
+	}
+	return out.GetDestination()
+}
+
+// routeNamespace returns the namespace of the virtualservice declaring the http route, used to expand the short
+// names of its destinations.
+func routeNamespace(route *networking.HTTPRoute, virtualServices []*v1.VirtualService) string {
+	for _, vs := range virtualServices {
+		for _, httpRoute := range vs.Spec.Http {
+			if httpRoute == route {
+				return vs.Namespace
+			}
+		}
+	}
+	return ""
+}
+
+// matchTrafficPolicy returns true when each setting of the expected policy, e.g. its loadBalancer or tls, equals
+// the one of the effective policy. Settings missing from the expected policy are not compared.
+func matchTrafficPolicy(got, want *networking.TrafficPolicy) bool {
+	match := true
+	gotReflect := got.ProtoReflect()
+	want.ProtoReflect().Range(func(fd protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if fd.Kind() != protoreflect.MessageKind || fd.IsList() || fd.IsMap() {
+			return true
+		}
+		if !gotReflect.Has(fd) || !proto.Equal(gotReflect.Get(fd).Message().Interface(), value.Message().Interface()) {
+			match = false
+		}
+		return match
+	})
+	return match
+}
@@ -0,0 +1,95 @@
+package unit
+
+import (
+	"testing"
+
+	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	networking "istio.io/api/networking/v1"
+	v1 "istio.io/client-go/pkg/apis/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestEffectiveTrafficPolicy(t *testing.T) {
+	roundRobin := &networking.LoadBalancerSettings{LbPolicy: &networking.LoadBalancerSettings_Simple{Simple: networking.LoadBalancerSettings_ROUND_ROBIN}}
+	leastRequest := &networking.LoadBalancerSettings{LbPolicy: &networking.LoadBalancerSettings_Simple{Simple: networking.LoadBalancerSettings_LEAST_REQUEST}}
+	mutual := &networking.ClientTLSSettings{Mode: networking.ClientTLSSettings_ISTIO_MUTUAL}
+	disable := &networking.ClientTLSSettings{Mode: networking.ClientTLSSettings_DISABLE}
+	config := &parser.Config{DestinationRules: []*v1.DestinationRule{{
+		ObjectMeta: metav1.ObjectMeta{Name: "reviews", Namespace: "reviews"},
+		Spec: networking.DestinationRule{
+			Host: "reviews",
+			TrafficPolicy: &networking.TrafficPolicy{
+				LoadBalancer: roundRobin,
+				Tls:          mutual,
+				PortLevelSettings: []*networking.TrafficPolicy_PortTrafficPolicy{{
+					Port: &networking.PortSelector{Number: 9080},
+					Tls:  disable,
+				}},
+			},
+			Subsets: []*networking.Subset{
+				{Name: "v1"},
+				{Name: "v2", TrafficPolicy: &networking.TrafficPolicy{LoadBalancer: leastRequest}},
+			},
+		},
+	}, {
+		ObjectMeta: metav1.ObjectMeta{Name: "wildcard", Namespace: "istio-system"},
+		Spec: networking.DestinationRule{
+			Host:          "*.svc.cluster.local",
+			TrafficPolicy: &networking.TrafficPolicy{Tls: disable},
+		},
+	}}}
+	tests := []struct {
+		name        string
+		namespace   string
+		destination *networking.Destination
+		want        *networking.TrafficPolicy
+	}{
+		{
+			name:        "host policy",
+			namespace:   "reviews",
+			destination: &networking.Destination{Host: "reviews", Subset: "v1"},
+			want:        &networking.TrafficPolicy{LoadBalancer: roundRobin, Tls: mutual},
+		},
+		{
+			name:        "subset overrides the host policy",
+			namespace:   "example",
+			destination: &networking.Destination{Host: "reviews.reviews.svc.cluster.local", Subset: "v2"},
+			want:        &networking.TrafficPolicy{LoadBalancer: leastRequest, Tls: mutual},
+		},
+		{
+			name:        "port level settings",
+			namespace:   "reviews",
+			destination: &networking.Destination{Host: "reviews", Port: &networking.PortSelector{Number: 9080}},
+			want:        &networking.TrafficPolicy{LoadBalancer: roundRobin, Tls: disable},
+		},
+		{
+			name:        "wildcard host",
+			namespace:   "ratings",
+			destination: &networking.Destination{Host: "ratings"},
+			want:        &networking.TrafficPolicy{Tls: disable},
+		},
+		{
+			name:        "no destinationrule",
+			destination: &networking.Destination{Host: "example.com"},
+			want:        &networking.TrafficPolicy{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := effectiveTrafficPolicy(config, tt.namespace, tt.destination)
+			require.True(t, proto.Equal(tt.want, got), "got %v", got)
+		})
+	}
+}
+
+func TestMatchTrafficPolicy(t *testing.T) {
+	got := &networking.TrafficPolicy{
+		LoadBalancer: &networking.LoadBalancerSettings{LbPolicy: &networking.LoadBalancerSettings_Simple{Simple: networking.LoadBalancerSettings_LEAST_REQUEST}},
+		Tls:          &networking.ClientTLSSettings{Mode: networking.ClientTLSSettings_ISTIO_MUTUAL},
+	}
+	require.True(t, matchTrafficPolicy(got, &networking.TrafficPolicy{Tls: &networking.ClientTLSSettings{Mode: networking.ClientTLSSettings_ISTIO_MUTUAL}}))
+	require.False(t, matchTrafficPolicy(got, &networking.TrafficPolicy{Tls: &networking.ClientTLSSettings{Mode: networking.ClientTLSSettings_DISABLE}}))
+	require.False(t, matchTrafficPolicy(got, &networking.TrafficPolicy{ConnectionPool: &networking.ConnectionPoolSettings{}}))
+}
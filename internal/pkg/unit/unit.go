@@ -4,43 +4,108 @@
 package unit
 
 import (
+	"cmp"
 	"fmt"
 	"reflect"
 	"slices"
+	"strings"
+	"time"
 
+	"github.com/getyourguide/istio-config-validator/internal/pkg/authz"
+	"github.com/getyourguide/istio-config-validator/internal/pkg/lint"
 	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
+	"google.golang.org/protobuf/proto"
 	networking "istio.io/api/networking/v1"
 	v1 "istio.io/client-go/pkg/apis/networking/v1"
 )
 
 // Run is the entrypoint to run all unit tests defined in test cases
-func Run(testfiles, configfiles []string, strict bool) ([]string, []string, error) {
+func Run(testfiles, configfiles []string, strict bool, opts ...optionFunc) ([]string, []string, error) {
 	var summary, details []string
+	o := options{now: time.Now()}
+	for _, opt := range opts {
+		opt(&o)
+	}
 
 	testCases, err := parser.ParseTestCases(testfiles, strict)
 	if err != nil {
 		return nil, nil, fmt.Errorf("parsing testcases failed: %w", err)
 	}
 
-	virtualServices, err := parser.ParseVirtualServices(configfiles)
+	config, err := parser.ParseConfig(configfiles)
 	if err != nil {
-		return nil, nil, fmt.Errorf("parsing virtualservices failed: %w", err)
+		return nil, nil, fmt.Errorf("parsing istio config failed: %w", err)
+	}
+	virtualServices := append(slices.Clone(config.VirtualServices), config.GatewayAPIVirtualServices()...)
+	virtualServices = append(virtualServices, config.IngressVirtualServices()...)
+	gateways := append(slices.Clone(config.Gateways), config.GatewayAPIGateways()...)
+	gateways = append(gateways, config.IngressGateways()...)
+
+	warnings := lint.VirtualServices(config.VirtualServices)
+	warnings = append(warnings, lint.DestinationRules(config)...)
+	warnings = append(warnings, lint.Ingresses(config)...)
+	for _, warning := range warnings {
+		details = append(details, "WARN "+warning.String())
+	}
+	if o.checkDestinations {
+		unresolved := lint.Destinations(config)
+		for _, warning := range unresolved {
+			details = append(details, "FAIL "+warning.String())
+		}
+		if len(unresolved) > 0 {
+			return summary, details, fmt.Errorf("%d destinations do not resolve to a service", len(unresolved))
+		}
 	}
 
 	inputCount := 0
 	for _, testCase := range testCases {
 		details = append(details, "running test: "+testCase.Description)
-		inputs, err := testCase.Request.Unfold()
+		inputs, err := testCase.Inputs()
 		if err != nil {
 			return summary, details, err
 		}
+		pathNormalization := o.pathNormalization
+		if testCase.PathNormalization != "" {
+			if pathNormalization, err = ParsePathNormalization(testCase.PathNormalization); err != nil {
+				return summary, details, fmt.Errorf("test %q: %w", testCase.Description, err)
+			}
+		}
+		var testGateways []*v1.Gateway
+		if testCase.Gateway != "" {
+			if testGateways, err = findGateways(gateways, testCase.Gateway); err != nil {
+				return summary, details, fmt.Errorf("test %q: %w", testCase.Description, err)
+			}
+		}
+		var sidecar *v1.Sidecar
+		if testCase.Source != nil {
+			if testGateways != nil {
+				return summary, details, fmt.Errorf("test %q: requests are sent either through a gateway or from a source", testCase.Description)
+			}
+			sidecar = findSidecar(config.Sidecars, testCase.Source)
+		}
+		router := requestRouter{
+			config:            config,
+			virtualServices:   virtualServices,
+			gateways:          testGateways,
+			gatewayRef:        testCase.Gateway,
+			sidecar:           sidecar,
+			pathNormalization: pathNormalization,
+			now:               o.now,
+		}
 		for _, input := range inputs {
-			checkHosts := true
-			route, err := GetRoute(input, virtualServices, checkHosts)
+			route, normalized, err := router.route(input)
 			if err != nil {
 				details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
-				return summary, details, fmt.Errorf("error getting destinations: %v", err)
+				return summary, details, err
+			}
+			var redirects redirectChain
+			if testCase.FollowRedirects {
+				if redirects, route, normalized, err = followRedirects(router, normalized, route); err != nil {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, err
+				}
 			}
+			checkHosts := true
 			if route.Delegate != nil {
 				if testCase.Delegate != nil {
 					if reflect.DeepEqual(route.Delegate, testCase.Delegate) != testCase.WantMatch {
@@ -49,14 +114,14 @@ func Run(testfiles, configfiles []string, strict bool) ([]string, []string, erro
 					}
 					details = append(details, fmt.Sprintf("PASS input:[%v]", input))
 				}
-				if testCase.Route != nil {
+				if assertsDelegatedRoute(testCase) {
 					vs, err := GetDelegatedVirtualService(route.Delegate, virtualServices)
 					if err != nil {
 						details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
 						return summary, details, fmt.Errorf("error getting delegate virtual service: %v", err)
 					}
 					checkHosts = false
-					route, err = GetRoute(input, []*v1.VirtualService{vs}, checkHosts)
+					route, err = GetRoute(normalized, []*v1.VirtualService{vs}, checkHosts)
 					if err != nil {
 						details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
 						return summary, details, fmt.Errorf("error getting destinations for delegate %v: %v", route.Delegate, err)
@@ -64,36 +129,161 @@ func Run(testfiles, configfiles []string, strict bool) ([]string, []string, erro
 				}
 			}
 			if testCase.Route != nil {
-				if reflect.DeepEqual(route.Route, testCase.Route) != testCase.WantMatch {
+				if slices.EqualFunc(route.Route, testCase.Route, equalMessage) != testCase.WantMatch {
 					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
 					return summary, details, fmt.Errorf("destination missmatch=%v, want %v, rule matched: %v", route.Route, testCase.Route, route.Match)
 				}
 			}
 			if testCase.Rewrite != nil {
-				if reflect.DeepEqual(route.Rewrite, testCase.Rewrite) != testCase.WantMatch {
+				if proto.Equal(route.Rewrite, testCase.Rewrite) != testCase.WantMatch {
 					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
 					return summary, details, fmt.Errorf("rewrite missmatch=%v, want %v, rule matched: %v", route.Rewrite, testCase.Rewrite, route.Match)
 				}
 			}
 			if testCase.Fault != nil {
-				if reflect.DeepEqual(route.Fault, testCase.Fault) != testCase.WantMatch {
+				if proto.Equal(route.Fault, testCase.Fault) != testCase.WantMatch {
 					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
 					return summary, details, fmt.Errorf("fault missmatch=%v, want %v, rule matched: %v", route.Fault, testCase.Fault, route.Match)
 				}
 			}
 			if testCase.Headers != nil {
-				if reflect.DeepEqual(route.Headers, testCase.Headers) != testCase.WantMatch {
+				if proto.Equal(route.Headers, testCase.Headers) != testCase.WantMatch {
 					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
 					return summary, details, fmt.Errorf("headers missmatch=%v, want %v, rule matched: %v", route.Headers, testCase.Headers, route.Match)
 				}
 			}
 			if testCase.Redirect != nil {
-				if reflect.DeepEqual(route.Redirect, testCase.Redirect) != testCase.WantMatch {
+				if proto.Equal(route.Redirect, testCase.Redirect) != testCase.WantMatch {
 					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
 					return summary, details, fmt.Errorf("redirect missmatch=%v, want %v, rule matched: %v", route.Redirect, testCase.Redirect, route.Match)
 				}
 			}
+			if testCase.Distribution != nil {
+				match, observed, err := matchDistribution(route, testCase.Distribution)
+				if err != nil {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("error simulating distribution: %v", err)
+				}
+				if match != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("distribution missmatch=%v, want %v, rule matched: %v", observed, testCase.Distribution.Destinations, route.Match)
+				}
+			}
+			if testCase.Mirror != nil {
+				if proto.Equal(route.Mirror, testCase.Mirror) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("mirror missmatch=%v, want %v, rule matched: %v", route.Mirror, testCase.Mirror, route.Match)
+				}
+			}
+			if testCase.Mirrors != nil {
+				if slices.EqualFunc(route.Mirrors, testCase.Mirrors, equalMessage) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("mirrors missmatch=%v, want %v, rule matched: %v", route.Mirrors, testCase.Mirrors, route.Match)
+				}
+			}
+			if testCase.MirrorPercentage != nil {
+				if proto.Equal(route.MirrorPercentage, testCase.MirrorPercentage) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("mirrorPercentage missmatch=%v, want %v, rule matched: %v", route.MirrorPercentage, testCase.MirrorPercentage, route.Match)
+				}
+			}
+			if testCase.Timeout != nil {
+				if proto.Equal(route.Timeout, testCase.Timeout.Duration) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("timeout missmatch=%v, want %v, rule matched: %v", route.Timeout.AsDuration(), testCase.Timeout.AsDuration(), route.Match)
+				}
+			}
+			if testCase.Retries != nil {
+				if proto.Equal(route.Retries, testCase.Retries.HTTPRetry) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("retries missmatch=%v, want %v, rule matched: %v", route.Retries, testCase.Retries.HTTPRetry, route.Match)
+				}
+			}
+			if testCase.CorsPolicy != nil {
+				if proto.Equal(route.CorsPolicy, testCase.CorsPolicy.CorsPolicy) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("corsPolicy missmatch=%v, want %v, rule matched: %v", route.CorsPolicy, testCase.CorsPolicy.CorsPolicy, route.Match)
+				}
+			}
+			if testCase.CORS != nil {
+				match, response, err := matchCORS(input, route, testCase.CORS)
+				if err != nil {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, err
+				}
+				if match != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("cors missmatch=%+v, want %+v, rule matched: %v", response, *testCase.CORS, route.Match)
+				}
+			}
+			if testCase.DirectResponse != nil {
+				match, err := matchDirectResponse(route.DirectResponse, testCase.DirectResponse)
+				if err != nil {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, err
+				}
+				if match != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("directResponse missmatch=%v, want %v, rule matched: %v", route.DirectResponse, describeDirectResponse(testCase.DirectResponse), route.Match)
+				}
+			}
+			if testCase.ExpectResponse != nil {
+				got := resolveResponse(normalized, route)
+				if matchResponse(got, testCase.ExpectResponse, routeNamespace(route, virtualServices)) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("response missmatch=%v, want %+v, rule matched: %v", got, *testCase.ExpectResponse, route.Match)
+				}
+			}
+			if testCase.TrafficPolicy != nil {
+				destination := heaviestDestination(route)
+				got := effectiveTrafficPolicy(config, routeNamespace(route, virtualServices), destination)
+				if matchTrafficPolicy(got, testCase.TrafficPolicy.TrafficPolicy) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("trafficPolicy missmatch=%v, want %v, destination: %v, rule matched: %v", got, testCase.TrafficPolicy.TrafficPolicy, destination, route.Match)
+				}
+			}
+			if testCase.Authorization != nil {
+				request := authz.Request{Input: normalized, Workload: testCase.Authorization.Workload, Source: testCase.Source}
+				// The sidecar of the workload validates the token before authorizing the request. Rejected
+				// tokens leave the request unauthenticated.
+				request.Claims, _ = authenticate(config.RequestAuthentications, testCase.Authorization.Workload, input.JWT, o.now)
+				decision, err := authz.Evaluate(config.AuthorizationPolicies, request)
+				if err != nil {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, err
+				}
+				if decision.Match(testCase.Authorization) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("authorization missmatch=%v, want %v", decision, describeAuthorization(testCase.Authorization))
+				}
+			}
+			if testCase.Journey != nil {
+				got, err := journey(normalized, route, virtualServices)
+				if err != nil {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, err
+				}
+				if matchJourney(got, testCase.Journey) != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("journey missmatch=%v, want %v, rule matched: %v", got, describeJourney(testCase.Journey), route.Match)
+				}
+			}
+			var simulatedFaults *faultOutcomes
+			if testCase.FaultSimulation != nil {
+				match, outcomes := matchFaultSimulation(route, testCase.FaultSimulation)
+				if match != testCase.WantMatch {
+					details = append(details, fmt.Sprintf("FAIL input:[%v]", input))
+					return summary, details, fmt.Errorf("fault simulation missmatch=%v, want abort %+v delay %+v, rule matched: %v", outcomes, testCase.FaultSimulation.Abort, testCase.FaultSimulation.Delay, route.Match)
+				}
+				simulatedFaults = &outcomes
+			}
 			details = append(details, fmt.Sprintf("PASS input:[%v]", input))
+			if len(redirects) > 1 {
+				details = append(details, fmt.Sprintf("  redirects: %v", redirects))
+			}
+			if simulatedFaults != nil {
+				details = append(details, fmt.Sprintf("  faults: %v", simulatedFaults))
+			}
 		}
 		inputCount += len(inputs)
 		details = append(details, "===========================")
@@ -101,22 +291,146 @@ func Run(testfiles, configfiles []string, strict bool) ([]string, []string, erro
 	summary = append(summary, "Test summary:")
 	summary = append(summary, fmt.Sprintf(" - %d testfiles, %d configfiles", len(testfiles), len(configfiles)))
 	summary = append(summary, fmt.Sprintf(" - %d testcases with %d inputs passed", len(testCases), inputCount))
+	if len(warnings) > 0 {
+		summary = append(summary, fmt.Sprintf(" - %d lint warnings", len(warnings)))
+	}
 	return summary, details, nil
 }
 
-// GetRoute returns the route that matched a given input.
+// requestRouter routes the requests of a test case: through its gateways, from its source workload or, without
+// them, through all the virtualservices.
+type requestRouter struct {
+	config            *parser.Config
+	virtualServices   []*v1.VirtualService
+	gateways          []*v1.Gateway
+	gatewayRef        string
+	sidecar           *v1.Sidecar
+	pathNormalization PathNormalization
+	// now is the time tokens are validated at.
+	now time.Time
+}
+
+// route returns the route matching the request, and the request as matched: with its path normalized, and its
+// token in the authorization header along with its claims. Requests through a gateway are answered with a 401
+// when their token is invalid, and with a redirect to https by servers redirecting http. Requests from a source
+// whose sidecar hides their host get the route of hiddenHostRoute.
+func (r requestRouter) route(input parser.Input) (*networking.HTTPRoute, parser.Input, error) {
+	// Rules are matched against the normalized path, while the original one is reported.
+	normalized := input
+	normalized.URI = NormalizePath(input.URI, r.pathNormalization)
+	if input.JWT != "" {
+		normalized.Headers = bearerHeaders(input.Headers, input.JWT)
+	}
+	checkHosts := true
+	routable := visibleVirtualServices(r.virtualServices, r.sidecar, input)
+	var route *networking.HTTPRoute
+	if r.gateways != nil {
+		gateway, server := selectGatewayServer(r.gateways, input)
+		if server == nil {
+			return nil, normalized, fmt.Errorf("gateway %s has no server accepting the request", r.gatewayRef)
+		}
+		// Tokens are validated by the jwt filter of the gateway, before routing.
+		workload := parser.Workload{Namespace: gateway.Namespace, Labels: gateway.Spec.Selector}
+		claims, valid := authenticate(r.config.RequestAuthentications, workload, input.JWT, r.now)
+		if !valid {
+			route = unauthorizedRoute()
+		}
+		normalized.Claims = claims
+		normalized.Gateway = gateway.Namespace + "/" + gateway.Name
+		if scheme, _ := listener(input); route == nil && input.Protocol == "" && scheme == "http" && server.GetTls().GetHttpsRedirect() {
+			route = httpsRedirectRoute()
+		}
+		routable = boundVirtualServices(r.virtualServices, gateway, server, cmp.Or(input.SNI, input.Authority))
+	}
+	if route == nil && input.Protocol == "" {
+		route = hiddenHostRoute(r.virtualServices, r.sidecar, input)
+	}
+	if route != nil {
+		return route, normalized, nil
+	}
+	route, err := GetRoute(normalized, routable, checkHosts)
+	if err != nil {
+		return nil, normalized, fmt.Errorf("error getting destinations: %v", err)
+	}
+	return route, normalized, nil
+}
+
+// assertsDelegatedRoute returns true when the test case asserts fields of the route found in the delegated
+// virtualservice, rather than the delegate itself.
+func assertsDelegatedRoute(testCase *parser.TestCase) bool {
+	return testCase.Route != nil || testCase.Distribution != nil || testCase.Mirror != nil || testCase.Mirrors != nil ||
+		testCase.MirrorPercentage != nil || testCase.Timeout != nil || testCase.Retries != nil || testCase.CorsPolicy != nil ||
+		testCase.CORS != nil || testCase.DirectResponse != nil || testCase.ExpectResponse != nil ||
+		testCase.FaultSimulation != nil || testCase.TrafficPolicy != nil || testCase.Journey != nil
+}
+
+// matchDirectResponse returns true when the route answers with a direct response with the expected status and
+// a body matching the expected one.
+func matchDirectResponse(directResponse *networking.HTTPDirectResponse, want *parser.DirectResponse) (bool, error) {
+	if directResponse == nil {
+		return false, nil
+	}
+	if want.Status != 0 && directResponse.Status != want.Status {
+		return false, nil
+	}
+	if want.Body == nil {
+		return true, nil
+	}
+	body := directResponse.GetBody().GetString_()
+	if bytes := directResponse.GetBody().GetBytes(); bytes != nil {
+		body = string(bytes)
+	}
+	sm := &ExtendedStringMatch{want.Body.StringMatch}
+	match, err := sm.Match(body)
+	if err != nil {
+		return false, fmt.Errorf("matching direct response body failed: %w", err)
+	}
+	return match, nil
+}
+
+func describeDirectResponse(directResponse *parser.DirectResponse) string {
+	if directResponse.Body == nil {
+		return fmt.Sprintf("status:%d", directResponse.Status)
+	}
+	return fmt.Sprintf("status:%d body:{%v}", directResponse.Status, directResponse.Body.StringMatch)
+}
+
+func describeAuthorization(authorization *parser.Authorization) string {
+	out := "denied"
+	if authorization.Allowed {
+		out = "allowed"
+	}
+	if authorization.Policy != "" {
+		out += " by " + authorization.Policy
+	}
+	if authorization.Rule != nil {
+		out += fmt.Sprintf(" rule %d", *authorization.Rule)
+	}
+	return out
+}
+
+// GetRoute returns the route that matched a given input. Tls and tcp inputs are matched against the tls and
+// tcp routes, see getL4Route.
 func GetRoute(input parser.Input, virtualServices []*v1.VirtualService, checkHosts bool) (*networking.HTTPRoute, error) {
+	if input.Protocol == parser.ProtocolTLS || input.Protocol == parser.ProtocolTCP {
+		return getL4Route(input, virtualServices, checkHosts)
+	}
+	if checkHosts {
+		virtualServices = hostVirtualServices(virtualServices, input.Authority)
+	}
 	for _, vs := range virtualServices {
 		spec := &vs.Spec
-		if checkHosts && !slices.Contains(spec.Hosts, input.Authority) {
-			continue
-		}
+		// Prefixes of the routes generated for HTTPRoutes only match whole path segments.
+		pathSegments := vs.Annotations[parser.RouteSemanticsAnnotation] == "gateway"
 
 		for _, httpRoute := range spec.Http {
 			if len(httpRoute.Match) == 0 {
 				return httpRoute, nil
 			}
 			for _, matchBlock := range httpRoute.Match {
+				if pathSegments && !matchPathSegments(input.URI, matchBlock.GetUri().GetPrefix()) {
+					continue
+				}
 				if match, err := matchRequest(input, matchBlock); err != nil {
 					return &networking.HTTPRoute{}, err
 				} else if match {
@@ -129,6 +443,41 @@ func GetRoute(input parser.Input, virtualServices []*v1.VirtualService, checkHos
 	return &networking.HTTPRoute{}, nil
 }
 
+// hostVirtualServices returns the virtualservices with the host, followed by the ones with a wildcard host
+// matching it, the most specific wildcard first. Short hosts are expanded in the namespace of the
+// virtualservice, so that "reviews" and "reviews.shop.svc.cluster.local" are the same host in namespace shop.
+func hostVirtualServices(virtualServices []*v1.VirtualService, host string) []*v1.VirtualService {
+	var exact, wildcard []*v1.VirtualService
+	specificity := map[*v1.VirtualService]int{}
+	for _, vs := range virtualServices {
+		if slices.ContainsFunc(vs.Spec.Hosts, func(vsHost string) bool {
+			return vsHost == host || parser.FQDN(vsHost, vs.Namespace) == parser.FQDN(host, vs.Namespace)
+		}) {
+			exact = append(exact, vs)
+			continue
+		}
+		for _, vsHost := range vs.Spec.Hosts {
+			if strings.HasPrefix(vsHost, "*") && parser.MatchHost(host, vsHost) {
+				specificity[vs] = max(specificity[vs], len(vsHost))
+			}
+		}
+		if _, ok := specificity[vs]; ok {
+			wildcard = append(wildcard, vs)
+		}
+	}
+	slices.SortStableFunc(wildcard, func(a, b *v1.VirtualService) int {
+		return cmp.Compare(specificity[b], specificity[a])
+	})
+	return append(exact, wildcard...)
+}
+
+// matchPathSegments returns true when the prefix matches whole segments of the path, as Gateway API prefix
+// matches do: "/foo" matches "/foo" and "/foo/bar" but not "/foobar".
+func matchPathSegments(path, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
 // GetDelegatedVirtualService returns the virtualservice matching namespace/name matching the delegate argument.
 func GetDelegatedVirtualService(delegate *networking.Delegate, virtualServices []*v1.VirtualService) (*v1.VirtualService, error) {
 	for _, vs := range virtualServices {
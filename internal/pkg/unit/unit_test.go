@@ -1,11 +1,14 @@
 package unit
 
 import (
+	"fmt"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/getyourguide/istio-config-validator/internal/pkg/parser"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 	networking "istio.io/api/networking/v1"
 	v1 "istio.io/client-go/pkg/apis/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -27,6 +30,187 @@ func TestRunDelegate(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestRunHAR(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_har_test.yml"}
+	configfiles := []string{"../../../examples/virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunCookie(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_cookie_test.yml"}
+	configfiles := []string{"../../../examples/cookie_virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunCanary(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_canary_test.yml"}
+	configfiles := []string{"../../../examples/canary_virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunDirectResponse(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_direct_response_test.yml"}
+	configfiles := []string{"../../../examples/direct_response_virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunTLS(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_tls_test.yml"}
+	configfiles := []string{"../../../examples/tls_virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunGateway(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_gateway_test.yml"}
+	configfiles := []string{"../../../examples/gateway_virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunHTTPRoute(t *testing.T) {
+	testcasefiles := []string{"../../../examples/httproute_test.yml"}
+	configfiles := []string{"../../../examples/httproute.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunIngress(t *testing.T) {
+	testcasefiles := []string{"../../../examples/ingress_test.yml"}
+	configfiles := []string{"../../../examples/ingress.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunJourney(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_journey_test.yml"}
+	configfiles := []string{"../../../examples/journey_virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunFollowRedirects(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_redirect_test.yml"}
+	configfiles := []string{"../../../examples/redirect_virtualservice.yml"}
+	var strict bool
+	_, details, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+	require.Contains(t, details, "  redirects: http://travel.example.net/home -> http://travel.example.net/ -> https://www.travel.example.net/ (2 redirects)")
+}
+
+func TestRunJWT(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_jwt_test.yml"}
+	configfiles := []string{"../../../examples/jwt_virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+
+	// The expired token of the examples is valid before its expiration.
+	_, _, err = Run(testcasefiles, configfiles, strict, WithNow(time.Unix(1500000000, 0)))
+	require.ErrorContains(t, err, "response missmatch=200")
+}
+
+func TestRunSidecar(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_sidecar_test.yml"}
+	configfiles := []string{"../../../examples/sidecar_virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunAuthorization(t *testing.T) {
+	testcasefiles := []string{"../../../examples/authorizationpolicy_test.yml"}
+	configfiles := []string{"../../../examples/authorizationpolicy.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRunCheckDestinations(t *testing.T) {
+	testcasefiles := []string{"../../../examples/virtualservice_tls_test.yml"}
+	var strict bool
+
+	configfiles := []string{"../../../examples/tls_virtualservice.yml", "../../../examples/tls_services.yml"}
+	_, _, err := Run(testcasefiles, configfiles, strict, WithDestinationCheck(true))
+	require.NoError(t, err)
+
+	configfiles = []string{"../../../examples/tls_virtualservice.yml"}
+	_, details, err := Run(testcasefiles, configfiles, strict, WithDestinationCheck(true))
+	require.ErrorContains(t, err, "4 destinations do not resolve to a service")
+	require.Contains(t, details, `FAIL virtualservice/example/postgres: tcp[0]: destination "postgres-primary.db.svc.cluster.local" does not resolve to a service or service entry (postgres-primary.db.svc.cluster.local)`)
+}
+
+func TestMatchDirectResponse(t *testing.T) {
+	directResponse := &networking.HTTPDirectResponse{
+		Status: 503,
+		Body:   &networking.HTTPBody{Specifier: &networking.HTTPBody_String_{String_: "down for maintenance"}},
+	}
+	tests := []struct {
+		name           string
+		directResponse *networking.HTTPDirectResponse
+		want           *parser.DirectResponse
+		match          bool
+	}{
+		{
+			name:           "status and body",
+			directResponse: directResponse,
+			want: &parser.DirectResponse{Status: 503, Body: &parser.StringMatch{StringMatch: &networking.StringMatch{
+				MatchType: &networking.StringMatch_Regex{Regex: ".*maintenance"},
+			}}},
+			match: true,
+		},
+		{
+			name:           "status only",
+			directResponse: directResponse,
+			want:           &parser.DirectResponse{Status: 503},
+			match:          true,
+		},
+		{
+			name:           "different status",
+			directResponse: directResponse,
+			want:           &parser.DirectResponse{Status: 200},
+			match:          false,
+		},
+		{
+			name: "bytes body",
+			directResponse: &networking.HTTPDirectResponse{
+				Status: 200,
+				Body:   &networking.HTTPBody{Specifier: &networking.HTTPBody_Bytes{Bytes: []byte("ok")}},
+			},
+			want: &parser.DirectResponse{Body: &parser.StringMatch{StringMatch: &networking.StringMatch{
+				MatchType: &networking.StringMatch_Exact{Exact: "ok"},
+			}}},
+			match: true,
+		},
+		{
+			name:           "no direct response",
+			directResponse: nil,
+			want:           &parser.DirectResponse{Status: 503},
+			match:          false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchDirectResponse(tt.directResponse, tt.want)
+			require.NoError(t, err)
+			require.Equal(t, tt.match, got)
+		})
+	}
+}
+
 func TestGetRoute(t *testing.T) {
 	type args struct {
 		input           parser.Input
@@ -250,6 +434,75 @@ func TestGetRoute(t *testing.T) {
 	}
 }
 
+func TestHostVirtualServices(t *testing.T) {
+	virtualService := func(hosts ...string) *v1.VirtualService {
+		return &v1.VirtualService{Spec: networking.VirtualService{Hosts: hosts}}
+	}
+	all := virtualService("*")
+	wildcard := virtualService("*.example.com")
+	exact := virtualService("www.example.com")
+	other := virtualService("www.example.org")
+	got := hostVirtualServices([]*v1.VirtualService{all, wildcard, other, exact}, "www.example.com")
+	require.Equal(t, []*v1.VirtualService{exact, wildcard, all}, got)
+}
+
+func TestHostVirtualServicesUnknownHost(t *testing.T) {
+	virtualServices := []*v1.VirtualService{{Spec: networking.VirtualService{Hosts: []string{"*.example.com", "www.example.org"}}}}
+	require.Empty(t, hostVirtualServices(virtualServices, "api.example.org"))
+	checkHosts := true
+	route, err := GetRoute(parser.Input{Authority: "api.example.org", URI: "/"}, virtualServices, checkHosts)
+	require.NoError(t, err)
+	require.Empty(t, route.Route)
+}
+
+func TestRunWildcardHosts(t *testing.T) {
+	testcasefiles := []string{"testdata/wildcard/virtualservice_test.yml"}
+	configfiles := []string{"testdata/wildcard/virtualservice.yml"}
+	var strict bool
+	_, _, err := Run(testcasefiles, configfiles, strict)
+	require.NoError(t, err)
+}
+
+func TestRouteAssertionsAfterPrinting(t *testing.T) {
+	virtualServices := []*v1.VirtualService{{Spec: networking.VirtualService{
+		Hosts: []string{"www.example.com"},
+		Http: []*networking.HTTPRoute{{
+			Rewrite: &networking.HTTPRewrite{Uri: "/v2"},
+			Headers: &networking.Headers{Request: &networking.Headers_HeaderOperations{Set: map[string]string{"x-version": "v2"}}},
+		}},
+	}}}
+	checkHosts := true
+	route, err := GetRoute(parser.Input{Authority: "www.example.com", URI: "/"}, virtualServices, checkHosts)
+	require.NoError(t, err)
+	wantRewrite := &networking.HTTPRewrite{Uri: "/v2"}
+	wantHeaders := &networking.Headers{Request: &networking.Headers_HeaderOperations{Set: map[string]string{"x-version": "v2"}}}
+	// Printing a message, e.g. in the details of a previous input, changes its internal state.
+	_ = fmt.Sprint(wantRewrite, wantHeaders)
+	require.False(t, reflect.DeepEqual(route.Rewrite, wantRewrite))
+	require.True(t, proto.Equal(route.Rewrite, wantRewrite))
+	require.True(t, proto.Equal(route.Headers, wantHeaders))
+}
+
+func TestHostVirtualServicesShortHosts(t *testing.T) {
+	short := &v1.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "shop"},
+		Spec:       networking.VirtualService{Hosts: []string{"reviews"}},
+	}
+	other := &v1.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "payments"},
+		Spec:       networking.VirtualService{Hosts: []string{"reviews"}},
+	}
+	require.Equal(t, []*v1.VirtualService{short}, hostVirtualServices([]*v1.VirtualService{short, other}, "reviews.shop.svc.cluster.local"))
+	require.Equal(t, []*v1.VirtualService{short, other}, hostVirtualServices([]*v1.VirtualService{short, other}, "reviews"))
+}
+
+func TestMatchPathSegments(t *testing.T) {
+	require.True(t, matchPathSegments("/tours", "/tours"))
+	require.True(t, matchPathSegments("/tours/berlin", "/tours/"))
+	require.True(t, matchPathSegments("/tours", "/"))
+	require.False(t, matchPathSegments("/toursearch", "/tours"))
+}
+
 func TestGetDelegatedVirtualService(t *testing.T) {
 	type args struct {
 		delegate        *networking.Delegate